@@ -0,0 +1,60 @@
+// Package account contains the self-service flows for the personal data of
+// the User, such as account deletion and data export. The personal data is
+// spread across many stores, and each store is accessed through a Store that
+// the adapters implement.
+package account
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultGracePeriod is the time before a scheduled deletion is purged.
+const DefaultGracePeriod = 30 * 24 * time.Hour
+
+// Account errors.
+var (
+	ErrUserIDRequired       = errors.New("account: user id required")
+	ErrDeletionNotScheduled = errors.New("account: deletion not scheduled")
+	ErrDeletionScheduled    = errors.New("account: deletion already scheduled")
+	ErrGracePeriodNotOver   = errors.New("account: grace period not over")
+
+	// ErrGracePeriodOver is returned by CancelDeletion when the account may be
+	// partially erased already.
+	ErrGracePeriodOver = errors.New("account: grace period over")
+
+	ErrStoreNameRequired   = errors.New("account: store name required")
+	ErrStoreNameDuplicated = errors.New("account: duplicated store name")
+)
+
+// Store holds personal data of the User, e.g. the users table, an orders
+// service, or the analytics events.
+type Store interface {
+	// Name is the key of the data in the export archive.
+	Name() string
+
+	// Export returns the personal data of the User, that is encoded as JSON.
+	Export(ctx context.Context, userID string) (any, error)
+
+	// Erase deletes or anonymizes the personal data of the User. It is
+	// retried on failure, so it must be idempotent.
+	Erase(ctx context.Context, userID string) error
+}
+
+func validateStores(stores []Store) error {
+	seen := make(map[string]bool)
+	for _, s := range stores {
+		name := s.Name()
+		if name == "" {
+			return ErrStoreNameRequired
+		}
+
+		if seen[name] {
+			return ErrStoreNameDuplicated
+		}
+		seen[name] = true
+	}
+
+	return nil
+}
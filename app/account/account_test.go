@@ -0,0 +1,128 @@
+package account_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/account"
+	"github.com/alextanhongpin/go-service-oriented-package/app/tokens"
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+)
+
+const cancelDeletionPurpose = "cancel_deletion"
+
+var (
+	errPasswordMismatch = errors.New("password mismatch")
+	errStoreDown        = errors.New("store down")
+)
+
+// memStore is a Store with the data by user id.
+type memStore struct {
+	name string
+	data map[string]any
+	err  error
+}
+
+func (s *memStore) Name() string {
+	return s.name
+}
+
+func (s *memStore) Export(ctx context.Context, userID string) (any, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return s.data[userID], nil
+}
+
+func (s *memStore) Erase(ctx context.Context, userID string) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	delete(s.data, userID)
+	return nil
+}
+
+type accountStub struct {
+	tokens    *tokens.Tokens
+	stores    []account.Store
+	scheduled map[string]time.Time
+	deleted   map[string]bool
+	notified  time.Time
+	token     string
+	grace     time.Duration
+}
+
+func newAccountStub(stores ...account.Store) *accountStub {
+	tk, err := tokens.New(tokens.Key{ID: "1", Secret: bytes.Repeat([]byte("a"), 32)})
+	if err != nil {
+		panic(err)
+	}
+	tk.Nonces = tokens.NewMemoryNonceStore()
+
+	return &accountStub{
+		tokens:    tk,
+		stores:    stores,
+		scheduled: make(map[string]time.Time),
+		deleted:   make(map[string]bool),
+		grace:     account.DefaultGracePeriod,
+	}
+}
+
+func (s *accountStub) Authenticate(ctx context.Context, userID string, password domain.Plaintext) error {
+	if password != "12345678" {
+		return errPasswordMismatch
+	}
+
+	return nil
+}
+
+func (s *accountStub) FindScheduledDeletion(ctx context.Context, userID string) (time.Time, error) {
+	return s.scheduled[userID], nil
+}
+
+func (s *accountStub) GracePeriod() time.Duration {
+	return s.grace
+}
+
+func (s *accountStub) ScheduleDeletion(ctx context.Context, userID string, deleteAt time.Time) error {
+	s.scheduled[userID] = deleteAt
+	return nil
+}
+
+func (s *accountStub) GenerateCancelDeletionToken(ctx context.Context, userID string, deleteAt time.Time) (string, error) {
+	return s.tokens.Issue(userID, cancelDeletionPurpose, time.Until(deleteAt))
+}
+
+func (s *accountStub) SendDeletionScheduledEmail(ctx context.Context, userID string, deleteAt time.Time, token string) error {
+	s.notified = deleteAt
+	s.token = token
+	return nil
+}
+
+func (s *accountStub) VerifyCancelDeletionToken(ctx context.Context, token string) (string, error) {
+	c, err := s.tokens.Use(ctx, token, cancelDeletionPurpose)
+	if err != nil {
+		return "", err
+	}
+
+	return c.Subject, nil
+}
+
+func (s *accountStub) CancelScheduledDeletion(ctx context.Context, userID string) error {
+	delete(s.scheduled, userID)
+	return nil
+}
+
+func (s *accountStub) Stores() []account.Store {
+	return s.stores
+}
+
+func (s *accountStub) DeleteAccount(ctx context.Context, userID string) error {
+	delete(s.scheduled, userID)
+	s.deleted[userID] = true
+	return nil
+}
@@ -0,0 +1,200 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+)
+
+// Flow for the User to delete the account. The deletion is scheduled after a
+// grace period, and can be cancelled with CancelDeletion until it is purged
+// with Purge.
+type requestDeletion interface {
+	// 1. Login the user before allowing deletion.
+	Authenticate(ctx context.Context, userID string, password domain.Plaintext) error
+
+	// 2. Load the scheduled deletion time. The zero time should be returned
+	// when no deletion is scheduled.
+	FindScheduledDeletion(ctx context.Context, userID string) (time.Time, error)
+
+	// 3. The time before the account is purged, e.g. DefaultGracePeriod.
+	GracePeriod() time.Duration
+
+	// 4. Schedule the deletion, and disable the account, e.g. by revoking the
+	// sessions.
+	ScheduleDeletion(ctx context.Context, userID string, deleteAt time.Time) error
+
+	// 5. Generate a token to cancel the deletion, with the user id as the
+	// subject, that expires at deleteAt, e.g. with tokens.Tokens.Issue. The
+	// User may not be able to login anymore, so the token authenticates
+	// CancelDeletion.
+	GenerateCancelDeletionToken(ctx context.Context, userID string, deleteAt time.Time) (string, error)
+
+	// 6. Notify the User with a link containing the token to cancel the
+	// deletion.
+	SendDeletionScheduledEmail(ctx context.Context, userID string, deleteAt time.Time, token string) error
+}
+
+type RequestDeletionDto struct {
+	UserID   string
+	Password string
+}
+
+func (d RequestDeletionDto) Validate() error {
+	if d.UserID == "" {
+		return ErrUserIDRequired
+	}
+
+	return domain.Plaintext(d.Password).Validate()
+}
+
+// RequestDeletion returns the time when the account is purged.
+func RequestDeletion(ctx context.Context, steps requestDeletion, dto RequestDeletionDto) (time.Time, error) {
+	if err := domain.Validate(dto); err != nil {
+		return time.Time{}, err
+	}
+
+	if err := steps.Authenticate(ctx, dto.UserID, domain.Plaintext(dto.Password)); err != nil {
+		return time.Time{}, err
+	}
+
+	scheduled, err := steps.FindScheduledDeletion(ctx, dto.UserID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !scheduled.IsZero() {
+		return time.Time{}, ErrDeletionScheduled
+	}
+
+	deleteAt := time.Now().Add(steps.GracePeriod())
+	if err := steps.ScheduleDeletion(ctx, dto.UserID, deleteAt); err != nil {
+		return time.Time{}, err
+	}
+
+	token, err := steps.GenerateCancelDeletionToken(ctx, dto.UserID, deleteAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := steps.SendDeletionScheduledEmail(ctx, dto.UserID, deleteAt, token); err != nil {
+		return time.Time{}, err
+	}
+
+	return deleteAt, nil
+}
+
+// Flow for the User to cancel a scheduled deletion during the grace period,
+// with the link sent by RequestDeletion.
+type cancelDeletion interface {
+	// 1. Verify the token, and return the user id of the subject, e.g. with
+	// tokens.Tokens.Use, which also prevents the token from being reused.
+	VerifyCancelDeletionToken(ctx context.Context, token string) (string, error)
+
+	// 2. Load the scheduled deletion time. The zero time should be returned
+	// when no deletion is scheduled.
+	FindScheduledDeletion(ctx context.Context, userID string) (time.Time, error)
+
+	// 3. Cancel the deletion, and enable the account again.
+	CancelScheduledDeletion(ctx context.Context, userID string) error
+}
+
+type CancelDeletionDto struct {
+	Token string
+}
+
+func (d CancelDeletionDto) Validate() error {
+	if d.Token == "" {
+		return errors.New("account: token required")
+	}
+
+	return nil
+}
+
+func CancelDeletion(ctx context.Context, steps cancelDeletion, dto CancelDeletionDto) error {
+	if err := domain.Validate(dto); err != nil {
+		return err
+	}
+
+	userID, err := steps.VerifyCancelDeletionToken(ctx, dto.Token)
+	if err != nil {
+		return err
+	}
+
+	scheduled, err := steps.FindScheduledDeletion(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if scheduled.IsZero() {
+		return ErrDeletionNotScheduled
+	}
+
+	if !time.Now().Before(scheduled) {
+		return ErrGracePeriodOver
+	}
+
+	return steps.CancelScheduledDeletion(ctx, userID)
+}
+
+// Flow to erase the account after the grace period, e.g. from a job that
+// runs periodically over the due deletions.
+type purge interface {
+	// 1. Load the scheduled deletion time. The zero time should be returned
+	// when no deletion is scheduled.
+	FindScheduledDeletion(ctx context.Context, userID string) (time.Time, error)
+
+	// 2. The stores with the personal data of the User.
+	Stores() []Store
+
+	// 3. Delete the account itself after all stores are erased.
+	DeleteAccount(ctx context.Context, userID string) error
+}
+
+type PurgeDto struct {
+	UserID string
+}
+
+func (d PurgeDto) Validate() error {
+	if d.UserID == "" {
+		return ErrUserIDRequired
+	}
+
+	return nil
+}
+
+// Purge stops at the first store that fails, and can be retried.
+func Purge(ctx context.Context, steps purge, dto PurgeDto) error {
+	if err := domain.Validate(dto); err != nil {
+		return err
+	}
+
+	scheduled, err := steps.FindScheduledDeletion(ctx, dto.UserID)
+	if err != nil {
+		return err
+	}
+
+	if scheduled.IsZero() {
+		return ErrDeletionNotScheduled
+	}
+
+	if time.Now().Before(scheduled) {
+		return ErrGracePeriodNotOver
+	}
+
+	stores := steps.Stores()
+	if err := validateStores(stores); err != nil {
+		return err
+	}
+
+	for _, s := range stores {
+		if err := s.Erase(ctx, dto.UserID); err != nil {
+			return fmt.Errorf("account: erase %s: %w", s.Name(), err)
+		}
+	}
+
+	return steps.DeleteAccount(ctx, dto.UserID)
+}
@@ -0,0 +1,159 @@
+package account_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/account"
+	"github.com/alextanhongpin/go-service-oriented-package/app/tokens"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestDeletion(t *testing.T) {
+	ctx := context.Background()
+	dto := account.RequestDeletionDto{UserID: "1", Password: "12345678"}
+
+	t.Run("success", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newAccountStub()
+		deleteAt, err := account.RequestDeletion(ctx, steps, dto)
+		assert.Nil(err)
+		assert.WithinDuration(time.Now().Add(account.DefaultGracePeriod), deleteAt, time.Second)
+		assert.Equal(deleteAt, steps.scheduled["1"])
+		assert.Equal(deleteAt, steps.notified)
+
+		_, err = account.RequestDeletion(ctx, steps, dto)
+		assert.ErrorIs(err, account.ErrDeletionScheduled)
+	})
+
+	t.Run("password mismatch", func(t *testing.T) {
+		dto := dto
+		dto.Password = "87654321"
+
+		steps := newAccountStub()
+		_, err := account.RequestDeletion(ctx, steps, dto)
+		assert.ErrorIs(t, err, errPasswordMismatch)
+		assert.Empty(t, steps.scheduled)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		_, err := account.RequestDeletion(ctx, newAccountStub(), account.RequestDeletionDto{Password: "12345678"})
+		assert.ErrorIs(t, err, account.ErrUserIDRequired)
+	})
+}
+
+func TestCancelDeletion(t *testing.T) {
+	ctx := context.Background()
+	request := func(t *testing.T, steps *accountStub) string {
+		t.Helper()
+
+		_, err := account.RequestDeletion(ctx, steps, account.RequestDeletionDto{UserID: "1", Password: "12345678"})
+		assert.Nil(t, err)
+		assert.NotEmpty(t, steps.token)
+
+		return steps.token
+	}
+
+	t.Run("success", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newAccountStub()
+		dto := account.CancelDeletionDto{Token: request(t, steps)}
+		assert.Nil(account.CancelDeletion(ctx, steps, dto))
+		assert.Empty(steps.scheduled)
+
+		// The token is single-use.
+		assert.ErrorIs(account.CancelDeletion(ctx, steps, dto), tokens.ErrTokenUsed)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newAccountStub()
+		request(t, steps)
+
+		err := account.CancelDeletion(ctx, steps, account.CancelDeletionDto{Token: "invalid-token"})
+		assert.ErrorIs(err, tokens.ErrTokenInvalid)
+		assert.NotEmpty(steps.scheduled)
+	})
+
+	t.Run("not scheduled", func(t *testing.T) {
+		steps := newAccountStub()
+		token, err := steps.GenerateCancelDeletionToken(ctx, "1", time.Now().Add(time.Hour))
+		assert.Nil(t, err)
+
+		err = account.CancelDeletion(ctx, steps, account.CancelDeletionDto{Token: token})
+		assert.ErrorIs(t, err, account.ErrDeletionNotScheduled)
+	})
+
+	t.Run("grace period over", func(t *testing.T) {
+		steps := newAccountStub()
+		token := request(t, steps)
+
+		steps.scheduled["1"] = time.Now().Add(-time.Second)
+		err := account.CancelDeletion(ctx, steps, account.CancelDeletionDto{Token: token})
+		assert.ErrorIs(t, err, account.ErrGracePeriodOver)
+	})
+}
+
+func TestPurge(t *testing.T) {
+	ctx := context.Background()
+
+	newStores := func() (*memStore, *memStore) {
+		users := &memStore{name: "users", data: map[string]any{"1": "john", "2": "jane"}}
+		orders := &memStore{name: "orders", data: map[string]any{"1": []string{"order-1"}}}
+		return users, orders
+	}
+
+	t.Run("success", func(t *testing.T) {
+		assert := assert.New(t)
+
+		users, orders := newStores()
+		steps := newAccountStub(users, orders)
+		steps.grace = 0
+
+		_, err := account.RequestDeletion(ctx, steps, account.RequestDeletionDto{UserID: "1", Password: "12345678"})
+		assert.Nil(err)
+
+		assert.Nil(account.Purge(ctx, steps, account.PurgeDto{UserID: "1"}))
+		assert.True(steps.deleted["1"])
+		assert.Equal(map[string]any{"2": "jane"}, users.data)
+		assert.Empty(orders.data)
+
+		err = account.Purge(ctx, steps, account.PurgeDto{UserID: "1"})
+		assert.ErrorIs(err, account.ErrDeletionNotScheduled)
+	})
+
+	t.Run("grace period not over", func(t *testing.T) {
+		assert := assert.New(t)
+
+		users, orders := newStores()
+		steps := newAccountStub(users, orders)
+
+		_, err := account.RequestDeletion(ctx, steps, account.RequestDeletionDto{UserID: "1", Password: "12345678"})
+		assert.Nil(err)
+
+		err = account.Purge(ctx, steps, account.PurgeDto{UserID: "1"})
+		assert.ErrorIs(err, account.ErrGracePeriodNotOver)
+		assert.Contains(users.data, "1")
+	})
+
+	t.Run("retry on store error", func(t *testing.T) {
+		assert := assert.New(t)
+
+		users, orders := newStores()
+		orders.err = errStoreDown
+		steps := newAccountStub(users, orders)
+		steps.scheduled["1"] = time.Now()
+
+		err := account.Purge(ctx, steps, account.PurgeDto{UserID: "1"})
+		assert.ErrorIs(err, errStoreDown)
+		assert.False(steps.deleted["1"])
+
+		orders.err = nil
+		assert.Nil(account.Purge(ctx, steps, account.PurgeDto{UserID: "1"}))
+		assert.True(steps.deleted["1"])
+	})
+}
@@ -0,0 +1,64 @@
+package account
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+)
+
+// Archive is the exported personal data of the User.
+type Archive struct {
+	UserID     string         `json:"user_id"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Data       map[string]any `json:"data"` // By store name.
+}
+
+// Flow for the User to export the personal data, e.g. for a GDPR subject
+// access request.
+type export interface {
+	// 1. The stores with the personal data of the User.
+	Stores() []Store
+}
+
+type ExportDto struct {
+	UserID string
+}
+
+func (d ExportDto) Validate() error {
+	if d.UserID == "" {
+		return ErrUserIDRequired
+	}
+
+	return nil
+}
+
+// Export returns the Archive encoded as JSON.
+func Export(ctx context.Context, steps export, dto ExportDto) ([]byte, error) {
+	if err := domain.Validate(dto); err != nil {
+		return nil, err
+	}
+
+	stores := steps.Stores()
+	if err := validateStores(stores); err != nil {
+		return nil, err
+	}
+
+	archive := Archive{
+		UserID:     dto.UserID,
+		ExportedAt: time.Now().UTC(),
+		Data:       make(map[string]any, len(stores)),
+	}
+	for _, s := range stores {
+		data, err := s.Export(ctx, dto.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("account: export %s: %w", s.Name(), err)
+		}
+
+		archive.Data[s.Name()] = data
+	}
+
+	return json.MarshalIndent(archive, "", "  ")
+}
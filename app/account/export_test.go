@@ -0,0 +1,53 @@
+package account_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/account"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newAccountStub(
+			&memStore{name: "users", data: map[string]any{"1": map[string]string{"name": "john"}}},
+			&memStore{name: "orders", data: map[string]any{"1": []string{"order-1"}}},
+		)
+
+		b, err := account.Export(ctx, steps, account.ExportDto{UserID: "1"})
+		assert.Nil(err)
+
+		var archive map[string]any
+		assert.Nil(json.Unmarshal(b, &archive))
+		assert.Equal("1", archive["user_id"])
+		assert.NotEmpty(archive["exported_at"])
+		assert.Equal(map[string]any{
+			"users":  map[string]any{"name": "john"},
+			"orders": []any{"order-1"},
+		}, archive["data"])
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		steps := newAccountStub(&memStore{name: "users", err: errStoreDown})
+		_, err := account.Export(ctx, steps, account.ExportDto{UserID: "1"})
+		assert.ErrorIs(t, err, errStoreDown)
+	})
+
+	t.Run("duplicated store name", func(t *testing.T) {
+		steps := newAccountStub(&memStore{name: "users"}, &memStore{name: "users"})
+		_, err := account.Export(ctx, steps, account.ExportDto{UserID: "1"})
+		assert.ErrorIs(t, err, account.ErrStoreNameDuplicated)
+	})
+
+	t.Run("store name required", func(t *testing.T) {
+		steps := newAccountStub(&memStore{})
+		_, err := account.Export(ctx, steps, account.ExportDto{UserID: "1"})
+		assert.ErrorIs(t, err, account.ErrStoreNameRequired)
+	})
+}
@@ -12,7 +12,8 @@ type changePassword interface {
 	// 1. Login the  user before allowing password change.
 	Authenticate(ctx context.Context, email domain.Email, oldPassword domain.Plaintext) error
 
-	// 2. Additional handling when new password is the same as the old domain.
+	// 2. Additional handling when new password is the same as the old domain,
+	// or any of the recent passwords if the steps keep a password history.
 	// Return an error if this is not allowed.
 	WhenPasswordIsReused(ctx context.Context, isPasswordReused bool) error
 
@@ -24,6 +25,7 @@ type ChangePasswordDto struct {
 	Email       string
 	OldPassword string
 	NewPassword string
+	SessionID   string `desc:"Optional current session, that is kept when other sessions are revoked"`
 }
 
 func (d ChangePasswordDto) Validate() error {
@@ -43,11 +45,28 @@ func ChangePassword(ctx context.Context, steps changePassword, dto ChangePasswor
 	oldPwd := domain.Plaintext(dto.OldPassword)
 	newPwd := domain.Plaintext(dto.NewPassword)
 
+	if err := checkPasswordPolicy(steps, newPwd, email, ""); err != nil {
+		return err
+	}
+
 	if err := steps.Authenticate(ctx, email, oldPwd); err != nil {
 		return err
 	}
 
-	if err := steps.WhenPasswordIsReused(ctx, oldPwd.Equal(newPwd)); err != nil {
+	reused := oldPwd.Equal(newPwd)
+	if !reused {
+		var err error
+		reused, err = isPasswordInHistory(ctx, steps, email, newPwd)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := steps.WhenPasswordIsReused(ctx, reused); err != nil {
+		return err
+	}
+
+	if err := checkPasswordBreached(ctx, steps, newPwd); err != nil {
 		return err
 	}
 
@@ -56,5 +75,17 @@ func ChangePassword(ctx context.Context, steps changePassword, dto ChangePasswor
 		return err
 	}
 
-	return steps.UpdatePassword(ctx, email, pwd)
+	if err := steps.UpdatePassword(ctx, email, pwd); err != nil {
+		return err
+	}
+
+	if err := rotateStamp(ctx, steps, email); err != nil {
+		return err
+	}
+
+	if err := savePasswordHistory(ctx, steps, email, pwd); err != nil {
+		return err
+	}
+
+	return revokeSessions(ctx, steps, email, dto.SessionID)
 }
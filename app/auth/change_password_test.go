@@ -0,0 +1,166 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/auth"
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var errPasswordReused = errors.New("password reused")
+
+type changePasswordStub struct {
+	policy  domain.PasswordPolicy
+	updated domain.Ciphertext
+}
+
+func (s *changePasswordStub) Authenticate(ctx context.Context, email domain.Email, oldPassword domain.Plaintext) error {
+	if !ciphertext.Compare(oldPassword) {
+		return errPasswordMismatch
+	}
+
+	return nil
+}
+
+func (s *changePasswordStub) WhenPasswordIsReused(ctx context.Context, isPasswordReused bool) error {
+	if isPasswordReused {
+		return errPasswordReused
+	}
+
+	return nil
+}
+
+func (s *changePasswordStub) UpdatePassword(ctx context.Context, email domain.Email, newPassword domain.Ciphertext) error {
+	s.updated = newPassword
+	return nil
+}
+
+func (s *changePasswordStub) PasswordPolicy() domain.PasswordPolicy {
+	return s.policy
+}
+
+// historyStub keeps the last historySize ciphertexts.
+type historyStub struct {
+	history []domain.Ciphertext
+}
+
+const historySize = 3
+
+func (s *historyStub) FindPasswordHistory(ctx context.Context, email domain.Email) ([]domain.Ciphertext, error) {
+	return s.history, nil
+}
+
+func (s *historyStub) SavePasswordHistory(ctx context.Context, email domain.Email, ciphertext domain.Ciphertext) error {
+	s.history = append(s.history, ciphertext)
+	if len(s.history) > historySize {
+		s.history = s.history[len(s.history)-historySize:]
+	}
+
+	return nil
+}
+
+type changePasswordHistoryStub struct {
+	changePasswordStub
+	historyStub
+}
+
+type revokeSessionsStub struct {
+	revoked bool
+	kept    string
+}
+
+func (s *revokeSessionsStub) RevokeOtherSessions(ctx context.Context, email domain.Email, keepSessionID string) error {
+	s.revoked = true
+	s.kept = keepSessionID
+	return nil
+}
+
+type changePasswordRevokeStub struct {
+	changePasswordStub
+	revokeSessionsStub
+}
+
+func TestChangePassword(t *testing.T) {
+	dto := auth.ChangePasswordDto{
+		Email:       "john.doe@mail.com",
+		OldPassword: string(password),
+		NewPassword: "correcthorsebatterystaple",
+	}
+
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		steps := &changePasswordStub{policy: domain.PasswordPolicy{MinScore: 3}}
+		assert.Nil(t, auth.ChangePassword(ctx, steps, dto))
+		assert.True(t, steps.updated.Compare("correcthorsebatterystaple"))
+	})
+
+	t.Run("authenticate error", func(t *testing.T) {
+		dto := dto
+		dto.OldPassword = "87654321"
+
+		steps := new(changePasswordStub)
+		assert.ErrorIs(t, auth.ChangePassword(ctx, steps, dto), errPasswordMismatch)
+	})
+
+	t.Run("password reused", func(t *testing.T) {
+		dto := dto
+		dto.NewPassword = dto.OldPassword
+
+		steps := new(changePasswordStub)
+		assert.ErrorIs(t, auth.ChangePassword(ctx, steps, dto), errPasswordReused)
+	})
+
+	t.Run("password too weak", func(t *testing.T) {
+		dto := dto
+		dto.NewPassword = "qwertyuiop"
+
+		steps := &changePasswordStub{policy: domain.PasswordPolicy{MinScore: 3}}
+		assert.ErrorIs(t, auth.ChangePassword(ctx, steps, dto), domain.ErrPasswordTooWeak)
+		assert.Empty(t, steps.updated)
+	})
+
+	t.Run("password history", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := &changePasswordHistoryStub{
+			historyStub: historyStub{history: []domain.Ciphertext{ciphertext}},
+		}
+
+		for _, pwd := range []string{"87654321", "abcdefgh", "correcthorse"} {
+			dto := dto
+			dto.NewPassword = pwd
+			assert.Nil(auth.ChangePassword(ctx, steps, dto))
+		}
+		assert.Len(steps.history, historySize)
+
+		dto := dto
+		dto.NewPassword = "87654321"
+		// The oldest password is dropped from the history.
+		assert.ErrorIs(auth.ChangePassword(ctx, steps, dto), errPasswordReused)
+		dto.NewPassword = "zyxwvuts"
+		assert.Nil(auth.ChangePassword(ctx, steps, dto))
+		dto.NewPassword = "87654321"
+		assert.Nil(auth.ChangePassword(ctx, steps, dto))
+	})
+
+	t.Run("revoke other sessions", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dto := dto
+		dto.SessionID = "session-1"
+
+		steps := new(changePasswordRevokeStub)
+		assert.Nil(auth.ChangePassword(ctx, steps, dto))
+		assert.True(steps.revoked)
+		assert.Equal("session-1", steps.kept)
+
+		steps = new(changePasswordRevokeStub)
+		dto.NewPassword = dto.OldPassword
+		assert.ErrorIs(auth.ChangePassword(ctx, steps, dto), errPasswordReused)
+		assert.False(steps.revoked)
+	})
+}
@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+)
+
+// ErrEmailUnchanged is returned by RequestEmailChange when the new email is
+// the same as the current email, ignoring case.
+var ErrEmailUnchanged = errors.New("auth: new email is the same as the current email")
+
+// Flow for a logged-in User to change the email. The email is only changed
+// after the new email is confirmed with ConfirmEmailChange, and the current
+// email is notified with a link to UndoEmailChange.
+type requestEmailChange interface {
+	// 1. Login the user before allowing email change.
+	Authenticate(ctx context.Context, email domain.Email, password domain.Plaintext) error
+
+	// 2. Checks if the new email is already taken.
+	CheckEmailExists(ctx context.Context, email domain.Email) (bool, error)
+
+	// 3. What error to return if the new email exists?
+	WhenEmailExists(ctx context.Context, exists bool) error
+
+	// 4. Generate the tokens with both emails, e.g. with tokens.Tokens.Issue
+	// and different purposes. The confirm token should be short-lived, and the
+	// undo token should outlive it, so that the change can be reverted.
+	GenerateEmailChangeToken(ctx context.Context, email, newEmail domain.Email) (string, error)
+	GenerateEmailChangeUndoToken(ctx context.Context, email, newEmail domain.Email) (string, error)
+
+	// 5a. Send the email containing the confirmation link and token to the new
+	// email.
+	SendEmailChangeConfirmationEmail(ctx context.Context, newEmail domain.Email, token string) error
+
+	// 5b. Send the notice containing the undo link and token to the current
+	// email.
+	SendEmailChangeNoticeEmail(ctx context.Context, email, newEmail domain.Email, undoToken string) error
+}
+
+type RequestEmailChangeDto struct {
+	Email    string
+	NewEmail string
+	Password string
+}
+
+func (d RequestEmailChangeDto) Validate() error {
+	if err := domain.Validate(
+		domain.Email(d.Email),
+		domain.Email(d.NewEmail),
+		domain.Plaintext(d.Password),
+	); err != nil {
+		return err
+	}
+
+	if strings.EqualFold(d.Email, d.NewEmail) {
+		return ErrEmailUnchanged
+	}
+
+	return nil
+}
+
+func RequestEmailChange(ctx context.Context, steps requestEmailChange, dto RequestEmailChangeDto) error {
+	if err := domain.Validate(dto); err != nil {
+		return err
+	}
+
+	email := domain.Email(dto.Email)
+	newEmail := domain.Email(dto.NewEmail)
+	if err := steps.Authenticate(ctx, email, domain.Plaintext(dto.Password)); err != nil {
+		return err
+	}
+
+	if err := checkNewEmail(ctx, steps, newEmail); err != nil {
+		return err
+	}
+
+	token, err := steps.GenerateEmailChangeToken(ctx, email, newEmail)
+	if err != nil {
+		return err
+	}
+
+	undoToken, err := steps.GenerateEmailChangeUndoToken(ctx, email, newEmail)
+	if err != nil {
+		return err
+	}
+
+	if err := steps.SendEmailChangeConfirmationEmail(ctx, newEmail, token); err != nil {
+		return err
+	}
+
+	return steps.SendEmailChangeNoticeEmail(ctx, email, newEmail, undoToken)
+}
+
+// A continuation of RequestEmailChange, when the User opens the link sent to
+// the new email.
+type confirmEmailChange interface {
+	// 1. Verify the token provided, and return both emails.
+	VerifyEmailChangeToken(ctx context.Context, token string) (email, newEmail domain.Email, err error)
+
+	// 2. Checks again if the new email is taken, since it may be registered
+	// after the request.
+	CheckEmailExists(ctx context.Context, email domain.Email) (bool, error)
+
+	// 3. What error to return if the new email exists?
+	WhenEmailExists(ctx context.Context, exists bool) error
+
+	// 4. Replace the email of the User. The new email is verified.
+	UpdateEmail(ctx context.Context, email, newEmail domain.Email) error
+}
+
+type ConfirmEmailChangeDto struct {
+	Token string
+}
+
+func (d ConfirmEmailChangeDto) Validate() error {
+	if d.Token == "" {
+		return errors.New("auth: token required")
+	}
+
+	return nil
+}
+
+func ConfirmEmailChange(ctx context.Context, steps confirmEmailChange, dto ConfirmEmailChangeDto) error {
+	if err := domain.Validate(dto); err != nil {
+		return err
+	}
+
+	email, newEmail, err := steps.VerifyEmailChangeToken(ctx, dto.Token)
+	if err != nil {
+		return err
+	}
+
+	if err := checkNewEmail(ctx, steps, newEmail); err != nil {
+		return err
+	}
+
+	if err := steps.UpdateEmail(ctx, email, newEmail); err != nil {
+		return err
+	}
+
+	return rotateStamp(ctx, steps, newEmail)
+}
+
+// A continuation of RequestEmailChange, when the User opens the undo link
+// sent to the previous email, e.g. when the change was not requested by them.
+type undoEmailChange interface {
+	// 1. Verify the undo token provided, and return both emails.
+	VerifyEmailChangeUndoToken(ctx context.Context, token string) (email, newEmail domain.Email, err error)
+
+	// 2. Restore the email of the User if the change was confirmed, and cancel
+	// the pending change otherwise, so that the confirm token can no longer be
+	// used.
+	RestoreEmail(ctx context.Context, email, newEmail domain.Email) error
+}
+
+type UndoEmailChangeDto struct {
+	Token string
+}
+
+func (d UndoEmailChangeDto) Validate() error {
+	if d.Token == "" {
+		return errors.New("auth: token required")
+	}
+
+	return nil
+}
+
+// UndoEmailChange also revokes all sessions when the steps implement
+// RevokeOtherSessions, since the change may be made by someone else.
+func UndoEmailChange(ctx context.Context, steps undoEmailChange, dto UndoEmailChangeDto) error {
+	if err := domain.Validate(dto); err != nil {
+		return err
+	}
+
+	email, newEmail, err := steps.VerifyEmailChangeUndoToken(ctx, dto.Token)
+	if err != nil {
+		return err
+	}
+
+	if err := steps.RestoreEmail(ctx, email, newEmail); err != nil {
+		return err
+	}
+
+	if err := rotateStamp(ctx, steps, email); err != nil {
+		return err
+	}
+
+	return revokeSessions(ctx, steps, email, "")
+}
+
+type emailExists interface {
+	CheckEmailExists(ctx context.Context, email domain.Email) (bool, error)
+	WhenEmailExists(ctx context.Context, exists bool) error
+}
+
+func checkNewEmail(ctx context.Context, steps emailExists, email domain.Email) error {
+	exists, err := steps.CheckEmailExists(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	return steps.WhenEmailExists(ctx, exists)
+}
@@ -0,0 +1,224 @@
+package auth_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/auth"
+	"github.com/alextanhongpin/go-service-oriented-package/app/tokens"
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	emailChangePurpose     = "email_change"
+	emailChangeUndoPurpose = "email_change_undo"
+)
+
+// emailChangeStub has a single User, and cancels pending changes by
+// tracking the undone new emails.
+type emailChangeStub struct {
+	revokeSessionsStub
+
+	tokens    *tokens.Tokens
+	email     domain.Email
+	taken     domain.Email
+	undone    map[domain.Email]bool
+	confirm   string
+	undo      string
+	noticedTo domain.Email
+}
+
+func newEmailChangeStub(t *testing.T) *emailChangeStub {
+	t.Helper()
+
+	tk, err := tokens.New(tokens.Key{ID: "1", Secret: bytes.Repeat([]byte("a"), 32)})
+	assert.Nil(t, err)
+
+	return &emailChangeStub{
+		tokens: tk,
+		email:  "john.doe@mail.com",
+		taken:  "jane.doe@mail.com",
+		undone: make(map[domain.Email]bool),
+	}
+}
+
+func (s *emailChangeStub) Authenticate(ctx context.Context, email domain.Email, password domain.Plaintext) error {
+	if email != s.email || !ciphertext.Compare(password) {
+		return errPasswordMismatch
+	}
+
+	return nil
+}
+
+func (s *emailChangeStub) CheckEmailExists(ctx context.Context, email domain.Email) (bool, error) {
+	return email == s.email || email == s.taken, nil
+}
+
+func (s *emailChangeStub) WhenEmailExists(ctx context.Context, exists bool) error {
+	if exists {
+		return errEmailExists
+	}
+
+	return nil
+}
+
+func (s *emailChangeStub) issue(email, newEmail domain.Email, purpose string, ttl time.Duration) (string, error) {
+	return s.tokens.Issue(string(email)+" "+string(newEmail), purpose, ttl)
+}
+
+func (s *emailChangeStub) verify(token, purpose string) (domain.Email, domain.Email, error) {
+	c, err := s.tokens.Verify(token, purpose)
+	if err != nil {
+		return "", "", err
+	}
+
+	email, newEmail, _ := strings.Cut(c.Subject, " ")
+	return domain.Email(email), domain.Email(newEmail), nil
+}
+
+func (s *emailChangeStub) GenerateEmailChangeToken(ctx context.Context, email, newEmail domain.Email) (string, error) {
+	return s.issue(email, newEmail, emailChangePurpose, time.Hour)
+}
+
+func (s *emailChangeStub) GenerateEmailChangeUndoToken(ctx context.Context, email, newEmail domain.Email) (string, error) {
+	return s.issue(email, newEmail, emailChangeUndoPurpose, 7*24*time.Hour)
+}
+
+func (s *emailChangeStub) SendEmailChangeConfirmationEmail(ctx context.Context, newEmail domain.Email, token string) error {
+	s.confirm = token
+	return nil
+}
+
+func (s *emailChangeStub) SendEmailChangeNoticeEmail(ctx context.Context, email, newEmail domain.Email, undoToken string) error {
+	s.noticedTo = email
+	s.undo = undoToken
+	return nil
+}
+
+func (s *emailChangeStub) VerifyEmailChangeToken(ctx context.Context, token string) (domain.Email, domain.Email, error) {
+	email, newEmail, err := s.verify(token, emailChangePurpose)
+	if err != nil {
+		return "", "", err
+	}
+
+	if s.undone[newEmail] {
+		return "", "", errTokenInvalid
+	}
+
+	return email, newEmail, nil
+}
+
+func (s *emailChangeStub) UpdateEmail(ctx context.Context, email, newEmail domain.Email) error {
+	s.email = newEmail
+	return nil
+}
+
+func (s *emailChangeStub) VerifyEmailChangeUndoToken(ctx context.Context, token string) (domain.Email, domain.Email, error) {
+	return s.verify(token, emailChangeUndoPurpose)
+}
+
+func (s *emailChangeStub) RestoreEmail(ctx context.Context, email, newEmail domain.Email) error {
+	s.undone[newEmail] = true
+	if s.email == newEmail {
+		s.email = email
+	}
+
+	return nil
+}
+
+func TestEmailChange(t *testing.T) {
+	ctx := context.Background()
+	dto := auth.RequestEmailChangeDto{
+		Email:    "john.doe@mail.com",
+		NewEmail: "john@example.com",
+		Password: string(password),
+	}
+
+	t.Run("confirm", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newEmailChangeStub(t)
+		assert.Nil(auth.RequestEmailChange(ctx, steps, dto))
+		assert.Equal(domain.Email("john.doe@mail.com"), steps.noticedTo)
+
+		// The email is not changed until confirmed.
+		assert.Equal(domain.Email("john.doe@mail.com"), steps.email)
+
+		assert.Nil(auth.ConfirmEmailChange(ctx, steps, auth.ConfirmEmailChangeDto{Token: steps.confirm}))
+		assert.Equal(domain.Email("john@example.com"), steps.email)
+	})
+
+	t.Run("undo after confirm", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newEmailChangeStub(t)
+		assert.Nil(auth.RequestEmailChange(ctx, steps, dto))
+		assert.Nil(auth.ConfirmEmailChange(ctx, steps, auth.ConfirmEmailChangeDto{Token: steps.confirm}))
+
+		assert.Nil(auth.UndoEmailChange(ctx, steps, auth.UndoEmailChangeDto{Token: steps.undo}))
+		assert.Equal(domain.Email("john.doe@mail.com"), steps.email)
+		assert.True(steps.revoked)
+	})
+
+	t.Run("undo before confirm", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newEmailChangeStub(t)
+		assert.Nil(auth.RequestEmailChange(ctx, steps, dto))
+		assert.Nil(auth.UndoEmailChange(ctx, steps, auth.UndoEmailChangeDto{Token: steps.undo}))
+
+		err := auth.ConfirmEmailChange(ctx, steps, auth.ConfirmEmailChangeDto{Token: steps.confirm})
+		assert.ErrorIs(err, errTokenInvalid)
+		assert.Equal(domain.Email("john.doe@mail.com"), steps.email)
+	})
+
+	t.Run("tokens are not interchangeable", func(t *testing.T) {
+		steps := newEmailChangeStub(t)
+		assert.Nil(t, auth.RequestEmailChange(ctx, steps, dto))
+
+		err := auth.ConfirmEmailChange(ctx, steps, auth.ConfirmEmailChangeDto{Token: steps.undo})
+		assert.ErrorIs(t, err, tokens.ErrPurposeMismatch)
+	})
+
+	t.Run("password mismatch", func(t *testing.T) {
+		dto := dto
+		dto.Password = "87654321"
+
+		steps := newEmailChangeStub(t)
+		assert.ErrorIs(t, auth.RequestEmailChange(ctx, steps, dto), errPasswordMismatch)
+		assert.Empty(t, steps.confirm)
+	})
+
+	t.Run("email taken", func(t *testing.T) {
+		dto := dto
+		dto.NewEmail = "jane.doe@mail.com"
+
+		steps := newEmailChangeStub(t)
+		assert.ErrorIs(t, auth.RequestEmailChange(ctx, steps, dto), errEmailExists)
+		assert.Empty(t, steps.confirm)
+	})
+
+	t.Run("email taken before confirm", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newEmailChangeStub(t)
+		assert.Nil(auth.RequestEmailChange(ctx, steps, dto))
+
+		steps.taken = "john@example.com"
+		err := auth.ConfirmEmailChange(ctx, steps, auth.ConfirmEmailChangeDto{Token: steps.confirm})
+		assert.ErrorIs(err, errEmailExists)
+		assert.Equal(domain.Email("john.doe@mail.com"), steps.email)
+	})
+
+	t.Run("email unchanged", func(t *testing.T) {
+		dto := dto
+		dto.NewEmail = "John.Doe@mail.com"
+
+		steps := newEmailChangeStub(t)
+		assert.ErrorIs(t, auth.RequestEmailChange(ctx, steps, dto), auth.ErrEmailUnchanged)
+	})
+}
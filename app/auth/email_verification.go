@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+)
+
+// ErrEmailNotVerified can be returned by WhenEmailVerified to refuse Login
+// from an unverified account.
+var ErrEmailNotVerified = errors.New("auth: email not verified")
+
+// Flow to verify the email of a User, e.g. after Register.
+type requestEmailVerification interface {
+	// 1. Checks if the email is already verified. Return ErrUserNotFound when
+	// the User does not exist.
+	IsEmailVerified(ctx context.Context, email domain.Email) (bool, error)
+
+	// 2. What error to return if the email is already verified?
+	WhenEmailVerified(ctx context.Context, verified bool) error
+
+	// 3. Generate a token with the email as the subject. Add expiration when
+	// needed.
+	GenerateEmailVerificationToken(ctx context.Context, email domain.Email) (string, error)
+
+	// 4. Send the email containing the verification link and token to the
+	// email.
+	SendEmailVerificationEmail(ctx context.Context, email domain.Email, token string) error
+}
+
+type RequestEmailVerificationDto struct {
+	Email string
+}
+
+func (d RequestEmailVerificationDto) Validate() error {
+	return domain.Email(d.Email).Validate()
+}
+
+func RequestEmailVerification(ctx context.Context, steps requestEmailVerification, dto RequestEmailVerificationDto) error {
+	if err := domain.Validate(dto); err != nil {
+		return err
+	}
+
+	email := domain.Email(dto.Email)
+	verified, err := steps.IsEmailVerified(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	if err := steps.WhenEmailVerified(ctx, verified); err != nil {
+		return err
+	}
+
+	token, err := steps.GenerateEmailVerificationToken(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	return steps.SendEmailVerificationEmail(ctx, email, token)
+}
+
+// A continuation of RequestEmailVerification, when the User opens the link.
+type confirmEmail interface {
+	// 1. Verify the token provided. It should have the email as the subject.
+	VerifyEmailVerificationToken(ctx context.Context, token string) (domain.Email, error)
+
+	// 2. Mark the email as verified.
+	MarkEmailVerified(ctx context.Context, email domain.Email) error
+}
+
+type ConfirmEmailDto struct {
+	Token string
+}
+
+func (d ConfirmEmailDto) Validate() error {
+	if d.Token == "" {
+		return errors.New("auth: token required")
+	}
+
+	return nil
+}
+
+func ConfirmEmail(ctx context.Context, steps confirmEmail, dto ConfirmEmailDto) error {
+	if err := domain.Validate(dto); err != nil {
+		return err
+	}
+
+	email, err := steps.VerifyEmailVerificationToken(ctx, dto.Token)
+	if err != nil {
+		return err
+	}
+
+	return steps.MarkEmailVerified(ctx, email)
+}
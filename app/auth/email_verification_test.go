@@ -0,0 +1,82 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/auth"
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	errEmailVerified = errors.New("email verified")
+	errTokenInvalid  = errors.New("token invalid")
+)
+
+type emailVerificationStub struct {
+	verified bool
+	sent     string
+}
+
+func (s *emailVerificationStub) IsEmailVerified(ctx context.Context, email domain.Email) (bool, error) {
+	return s.verified, nil
+}
+
+func (s *emailVerificationStub) WhenEmailVerified(ctx context.Context, verified bool) error {
+	if verified {
+		return errEmailVerified
+	}
+
+	return nil
+}
+
+func (s *emailVerificationStub) GenerateEmailVerificationToken(ctx context.Context, email domain.Email) (string, error) {
+	return "token:" + string(email), nil
+}
+
+func (s *emailVerificationStub) SendEmailVerificationEmail(ctx context.Context, email domain.Email, token string) error {
+	s.sent = token
+	return nil
+}
+
+func (s *emailVerificationStub) VerifyEmailVerificationToken(ctx context.Context, token string) (domain.Email, error) {
+	if token != s.sent {
+		return "", errTokenInvalid
+	}
+
+	return "john.doe@mail.com", nil
+}
+
+func (s *emailVerificationStub) MarkEmailVerified(ctx context.Context, email domain.Email) error {
+	s.verified = true
+	return nil
+}
+
+func TestEmailVerification(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	steps := new(emailVerificationStub)
+
+	err := auth.RequestEmailVerification(ctx, steps, auth.RequestEmailVerificationDto{Email: "john.doe@mail.com"})
+	assert.Nil(err)
+	assert.Equal("token:john.doe@mail.com", steps.sent)
+
+	err = auth.ConfirmEmail(ctx, steps, auth.ConfirmEmailDto{Token: "invalid"})
+	assert.ErrorIs(err, errTokenInvalid)
+	assert.False(steps.verified)
+
+	err = auth.ConfirmEmail(ctx, steps, auth.ConfirmEmailDto{Token: steps.sent})
+	assert.Nil(err)
+	assert.True(steps.verified)
+
+	err = auth.RequestEmailVerification(ctx, steps, auth.RequestEmailVerificationDto{Email: "john.doe@mail.com"})
+	assert.ErrorIs(err, errEmailVerified)
+}
+
+func TestConfirmEmailValidation(t *testing.T) {
+	err := auth.ConfirmEmail(context.Background(), new(emailVerificationStub), auth.ConfirmEmailDto{})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+)
+
+// Flow to enroll a TOTP authenticator for a logged-in User. The secret is
+// pending until the User confirms it with a code in ConfirmTOTP.
+type enrollTOTP interface {
+	// 1. Save the secret as pending. An existing pending secret should be
+	// replaced.
+	SavePendingTOTPSecret(ctx context.Context, email domain.Email, secret domain.TOTPSecret) error
+}
+
+type EnrollTOTPDto struct {
+	Email  string
+	Issuer string `example:"Acme Co" desc:"Name of the service shown in the authenticator app"`
+}
+
+func (d EnrollTOTPDto) Validate() error {
+	if d.Issuer == "" {
+		return errors.New("auth: issuer required")
+	}
+
+	return domain.Email(d.Email).Validate()
+}
+
+// EnrollTOTP returns the otpauth:// provisioning URI of the new secret.
+func EnrollTOTP(ctx context.Context, steps enrollTOTP, dto EnrollTOTPDto) (string, error) {
+	if err := domain.Validate(dto); err != nil {
+		return "", err
+	}
+
+	email := domain.Email(dto.Email)
+
+	secret, err := domain.NewTOTPSecret()
+	if err != nil {
+		return "", err
+	}
+
+	if err := steps.SavePendingTOTPSecret(ctx, email, secret); err != nil {
+		return "", err
+	}
+
+	return secret.URI(dto.Issuer, email), nil
+}
+
+// A continuation of EnrollTOTP, where the User enters the first code from the
+// authenticator app.
+type confirmTOTP interface {
+	// 1. Load the pending secret.
+	FindPendingTOTPSecretByEmail(ctx context.Context, email domain.Email) (domain.TOTPSecret, error)
+
+	// 2. What error to return if the code does not match?
+	WhenTOTPMatch(ctx context.Context, match bool) error
+
+	// 3. Enable the secret as the User's second factor, and record the time
+	// step so that the same code cannot be used to login. Only called when the
+	// code matches.
+	SaveTOTPSecret(ctx context.Context, email domain.Email, secret domain.TOTPSecret, timeStep int64) error
+}
+
+type ConfirmTOTPDto struct {
+	Email string
+	Code  string
+}
+
+func (d ConfirmTOTPDto) Validate() error {
+	return domain.Validate(
+		domain.Email(d.Email),
+		domain.OTP(d.Code),
+	)
+}
+
+func ConfirmTOTP(ctx context.Context, steps confirmTOTP, dto ConfirmTOTPDto) error {
+	if err := domain.Validate(dto); err != nil {
+		return err
+	}
+
+	email := domain.Email(dto.Email)
+
+	secret, err := steps.FindPendingTOTPSecretByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	timeStep, match := secret.Verify(domain.OTP(dto.Code), time.Now())
+	if err := steps.WhenTOTPMatch(ctx, match); err != nil {
+		return err
+	}
+
+	// An unconfirmed secret is never enabled, even when the mismatch is
+	// allowed by WhenTOTPMatch.
+	if !match {
+		return nil
+	}
+
+	return steps.SaveTOTPSecret(ctx, email, secret, timeStep)
+}
@@ -0,0 +1,96 @@
+package auth_test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/auth"
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+type enrollTOTPStub struct {
+	pending  domain.TOTPSecret
+	enrolled domain.TOTPSecret
+	timeStep int64
+}
+
+func (s *enrollTOTPStub) SavePendingTOTPSecret(ctx context.Context, email domain.Email, secret domain.TOTPSecret) error {
+	s.pending = secret
+	return nil
+}
+
+func (s *enrollTOTPStub) FindPendingTOTPSecretByEmail(ctx context.Context, email domain.Email) (domain.TOTPSecret, error) {
+	return s.pending, nil
+}
+
+func (s *enrollTOTPStub) WhenTOTPMatch(ctx context.Context, match bool) error {
+	if !match {
+		return errTOTPMismatch
+	}
+
+	return nil
+}
+
+func (s *enrollTOTPStub) SaveTOTPSecret(ctx context.Context, email domain.Email, secret domain.TOTPSecret, timeStep int64) error {
+	s.enrolled = secret
+	s.timeStep = timeStep
+	return nil
+}
+
+// enrollTOTPFlagStub allows mismatched codes, e.g. to flag them instead.
+type enrollTOTPFlagStub struct {
+	*enrollTOTPStub
+}
+
+func (s *enrollTOTPFlagStub) WhenTOTPMatch(ctx context.Context, match bool) error {
+	return nil
+}
+
+func TestEnrollTOTP(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	steps := new(enrollTOTPStub)
+
+	uri, err := auth.EnrollTOTP(ctx, steps, auth.EnrollTOTPDto{
+		Email:  "john.doe@mail.com",
+		Issuer: "Acme",
+	})
+	assert.Nil(err)
+
+	u, err := url.Parse(uri)
+	assert.Nil(err)
+	assert.Equal("otpauth", u.Scheme)
+	assert.Equal(string(steps.pending), u.Query().Get("secret"))
+
+	assert.ErrorIs(auth.ConfirmTOTP(ctx, steps, auth.ConfirmTOTPDto{
+		Email: "john.doe@mail.com",
+		Code:  "000000x",
+	}), domain.ErrOTPInvalidFormat)
+
+	now := time.Now()
+	code, err := steps.pending.Code(now)
+	assert.Nil(err)
+
+	// The code of another time step.
+	other, err := steps.pending.Code(now.Add(time.Hour))
+	assert.Nil(err)
+
+	// Mismatch allowed.
+	assert.Nil(auth.ConfirmTOTP(ctx, &enrollTOTPFlagStub{steps}, auth.ConfirmTOTPDto{
+		Email: "john.doe@mail.com",
+		Code:  string(other),
+	}))
+	assert.Empty(steps.enrolled)
+	assert.Zero(steps.timeStep)
+
+	assert.Nil(auth.ConfirmTOTP(ctx, steps, auth.ConfirmTOTPDto{
+		Email: "john.doe@mail.com",
+		Code:  string(code),
+	}))
+	assert.Equal(steps.pending, steps.enrolled)
+	assert.Equal(domain.TOTPTimeStep(now), steps.timeStep)
+}
@@ -0,0 +1,271 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+)
+
+// External login errors.
+var (
+	ErrIDTokenRequired = errors.New("auth: id token required")
+
+	// ErrLinkRequired is returned by LoginExternal when an account with the
+	// email exists, and the external identity needs to be linked with
+	// LinkExternal after confirming the password.
+	ErrLinkRequired = errors.New("auth: link required")
+
+	// ErrExternalEmailUnverified is returned by LoginExternal when the email is
+	// not verified by the provider, since anyone could claim it.
+	ErrExternalEmailUnverified = errors.New("auth: external email not verified")
+
+	ErrExternalIdentityLinked    = errors.New("auth: external identity linked to another account")
+	ErrExternalIdentityNotLinked = errors.New("auth: external identity not linked")
+
+	// ErrLastLoginMethod is returned by UnlinkExternal when the User has no
+	// password and no other external identity to login with.
+	ErrLastLoginMethod = errors.New("auth: cannot remove last login method")
+)
+
+// ExternalIdentity is the User at an identity provider, e.g. Google or Apple,
+// from a validated ID token.
+type ExternalIdentity struct {
+	Issuer        string
+	Subject       string
+	Email         domain.Email
+	EmailVerified bool
+}
+
+// LinkRequiredError carries the email of the account to link to.
+type LinkRequiredError struct {
+	Email domain.Email
+}
+
+func (e *LinkRequiredError) Error() string {
+	return ErrLinkRequired.Error()
+}
+
+func (e *LinkRequiredError) Unwrap() error {
+	return ErrLinkRequired
+}
+
+// Flow for a User to login with an external identity. The ID token only proves
+// ownership of the external identity, so the optional steps of
+// loginSecondFactor and loginDevice are applied as in Login.
+type loginExternal interface {
+	// 1. Validate the ID token of the provider, e.g. the signature, issuer,
+	// audience, expiry and nonce of an OpenID Connect ID token.
+	VerifyIDToken(ctx context.Context, idToken, nonce string) (ExternalIdentity, error)
+
+	// 2. Load the User linked to the external identity. Return ErrUserNotFound
+	// when it is not linked.
+	FindIdentityByExternal(ctx context.Context, issuer, subject string) (Identity, error)
+
+	// 3. Checks if an account with the email exists.
+	CheckEmailExists(ctx context.Context, email domain.Email) (bool, error)
+
+	// 4. Create the User with the external identity linked, and the email
+	// verified.
+	CreateUserByExternalIdentity(ctx context.Context, ext ExternalIdentity) (Identity, error)
+}
+
+type LoginExternalDto struct {
+	IDToken   string
+	Nonce     string `desc:"Optional nonce sent in the authentication request"`
+	DeviceID  string `desc:"Optional identifier of the device or browser, e.g. from a cookie"`
+	UserAgent string `desc:"Optional client user agent"`
+}
+
+func (d LoginExternalDto) Validate() error {
+	if d.IDToken == "" {
+		return ErrIDTokenRequired
+	}
+
+	return nil
+}
+
+func LoginExternal(ctx context.Context, steps loginExternal, dto LoginExternalDto) (Identity, error) {
+	if err := domain.Validate(dto); err != nil {
+		return Identity{}, err
+	}
+
+	ext, err := steps.VerifyIDToken(ctx, dto.IDToken, dto.Nonce)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	attempt := LoginDto{
+		DeviceID:  dto.DeviceID,
+		UserAgent: dto.UserAgent,
+	}
+
+	id, err := steps.FindIdentityByExternal(ctx, ext.Issuer, ext.Subject)
+	if err == nil {
+		if err := loginIdentity(ctx, steps, id, attempt); err != nil {
+			return Identity{}, err
+		}
+
+		return id, nil
+	}
+
+	if !errors.Is(err, ErrUserNotFound) {
+		return Identity{}, err
+	}
+
+	// Do not trust an unverified email to create or find an account.
+	if !ext.EmailVerified {
+		return Identity{}, ErrExternalEmailUnverified
+	}
+
+	exists, err := steps.CheckEmailExists(ctx, ext.Email)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	// Only the owner of the account can link it, after confirming the
+	// password.
+	if exists {
+		return Identity{}, &LinkRequiredError{Email: ext.Email}
+	}
+
+	id, err = steps.CreateUserByExternalIdentity(ctx, ext)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	if err := rememberIdentity(ctx, steps, id, attempt); err != nil {
+		return Identity{}, err
+	}
+
+	return id, nil
+}
+
+// Flow for a User to link an external identity to the account.
+type linkExternal interface {
+	// 1. Login the user before allowing linking.
+	Authenticate(ctx context.Context, email domain.Email, password domain.Plaintext) error
+
+	// 2. Validate the ID token of the provider.
+	VerifyIDToken(ctx context.Context, idToken, nonce string) (ExternalIdentity, error)
+
+	// 3. Load the User linked to the external identity. Return ErrUserNotFound
+	// when it is not linked.
+	FindIdentityByExternal(ctx context.Context, issuer, subject string) (Identity, error)
+
+	// 4. Link the external identity to the account.
+	SaveExternalIdentity(ctx context.Context, email domain.Email, ext ExternalIdentity) error
+}
+
+type LinkExternalDto struct {
+	Email    string
+	Password string
+	IDToken  string
+	Nonce    string `desc:"Optional nonce sent in the authentication request"`
+}
+
+func (d LinkExternalDto) Validate() error {
+	if d.IDToken == "" {
+		return ErrIDTokenRequired
+	}
+
+	return domain.Validate(
+		domain.Email(d.Email),
+		domain.Plaintext(d.Password),
+	)
+}
+
+func LinkExternal(ctx context.Context, steps linkExternal, dto LinkExternalDto) error {
+	if err := domain.Validate(dto); err != nil {
+		return err
+	}
+
+	email := domain.Email(dto.Email)
+	if err := steps.Authenticate(ctx, email, domain.Plaintext(dto.Password)); err != nil {
+		return err
+	}
+
+	ext, err := steps.VerifyIDToken(ctx, dto.IDToken, dto.Nonce)
+	if err != nil {
+		return err
+	}
+
+	id, err := steps.FindIdentityByExternal(ctx, ext.Issuer, ext.Subject)
+	if err == nil {
+		if strings.EqualFold(string(id.Email), string(email)) {
+			return nil
+		}
+
+		return ErrExternalIdentityLinked
+	}
+
+	if !errors.Is(err, ErrUserNotFound) {
+		return err
+	}
+
+	return steps.SaveExternalIdentity(ctx, email, ext)
+}
+
+// Flow for a logged-in User to unlink an external identity.
+type unlinkExternal interface {
+	// 1. Load the external identities linked to the account.
+	FindExternalIdentitiesByEmail(ctx context.Context, email domain.Email) ([]ExternalIdentity, error)
+
+	// 2. Checks if the User can still login with a password.
+	HasPassword(ctx context.Context, email domain.Email) (bool, error)
+
+	// 3. Unlink the external identity.
+	DeleteExternalIdentity(ctx context.Context, email domain.Email, issuer, subject string) error
+}
+
+type UnlinkExternalDto struct {
+	Email   string
+	Issuer  string
+	Subject string
+}
+
+func (d UnlinkExternalDto) Validate() error {
+	if d.Issuer == "" || d.Subject == "" {
+		return errors.New("auth: issuer and subject required")
+	}
+
+	return domain.Email(d.Email).Validate()
+}
+
+func UnlinkExternal(ctx context.Context, steps unlinkExternal, dto UnlinkExternalDto) error {
+	if err := domain.Validate(dto); err != nil {
+		return err
+	}
+
+	email := domain.Email(dto.Email)
+	exts, err := steps.FindExternalIdentitiesByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	linked := false
+	for _, ext := range exts {
+		if ext.Issuer == dto.Issuer && ext.Subject == dto.Subject {
+			linked = true
+			break
+		}
+	}
+
+	if !linked {
+		return ErrExternalIdentityNotLinked
+	}
+
+	if len(exts) == 1 {
+		ok, err := steps.HasPassword(ctx, email)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return ErrLastLoginMethod
+		}
+	}
+
+	return steps.DeleteExternalIdentity(ctx, email, dto.Issuer, dto.Subject)
+}
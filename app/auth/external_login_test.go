@@ -0,0 +1,421 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/auth"
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	errIDTokenInvalid = errors.New("id token invalid")
+	errNonceMismatch  = errors.New("nonce mismatch")
+)
+
+const (
+	fakeIssuerURL = "https://accounts.example.com"
+	fakeClientID  = "client-id"
+)
+
+type idTokenClaims struct {
+	Issuer        string `json:"iss"`
+	Subject       string `json:"sub"`
+	Audience      string `json:"aud"`
+	ExpiresAt     int64  `json:"exp"`
+	Nonce         string `json:"nonce,omitempty"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// fakeIssuer issues EdDSA-signed ID tokens, like an OpenID Connect provider.
+type fakeIssuer struct {
+	pub  ed25519.PublicKey
+	priv ed25519.PrivateKey
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+
+	return &fakeIssuer{pub: pub, priv: priv}
+}
+
+func (i *fakeIssuer) issue(t *testing.T, c idTokenClaims) string {
+	t.Helper()
+
+	if c.Issuer == "" {
+		c.Issuer = fakeIssuerURL
+	}
+
+	if c.Audience == "" {
+		c.Audience = fakeClientID
+	}
+
+	if c.ExpiresAt == 0 {
+		c.ExpiresAt = time.Now().Add(time.Hour).Unix()
+	}
+
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"EdDSA","typ":"JWT"}`))
+	b, err := json.Marshal(c)
+	assert.Nil(t, err)
+
+	payload := header + "." + enc.EncodeToString(b)
+	return payload + "." + enc.EncodeToString(ed25519.Sign(i.priv, []byte(payload)))
+}
+
+// verify validates the ID token like a relying party.
+func (i *fakeIssuer) verify(idToken, nonce string) (auth.ExternalIdentity, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return auth.ExternalIdentity{}, errIDTokenInvalid
+	}
+
+	enc := base64.RawURLEncoding
+	sig, err := enc.DecodeString(parts[2])
+	if err != nil || !ed25519.Verify(i.pub, []byte(parts[0]+"."+parts[1]), sig) {
+		return auth.ExternalIdentity{}, errIDTokenInvalid
+	}
+
+	b, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return auth.ExternalIdentity{}, errIDTokenInvalid
+	}
+
+	var c idTokenClaims
+	if err := json.Unmarshal(b, &c); err != nil {
+		return auth.ExternalIdentity{}, errIDTokenInvalid
+	}
+
+	if c.Issuer != fakeIssuerURL || c.Audience != fakeClientID || time.Now().Unix() >= c.ExpiresAt {
+		return auth.ExternalIdentity{}, errIDTokenInvalid
+	}
+
+	if c.Nonce != nonce {
+		return auth.ExternalIdentity{}, errNonceMismatch
+	}
+
+	return auth.ExternalIdentity{
+		Issuer:        c.Issuer,
+		Subject:       c.Subject,
+		Email:         domain.Email(c.Email),
+		EmailVerified: c.EmailVerified,
+	}, nil
+}
+
+type externalKey struct {
+	issuer, subject string
+}
+
+// externalLoginStub has an account with password for john.doe@mail.com.
+type externalLoginStub struct {
+	issuer    *fakeIssuer
+	users     map[domain.Email]auth.Identity
+	passwords map[domain.Email]bool
+	links     map[externalKey]domain.Email
+}
+
+func newExternalLoginStub(t *testing.T) *externalLoginStub {
+	t.Helper()
+
+	return &externalLoginStub{
+		issuer: newFakeIssuer(t),
+		users: map[domain.Email]auth.Identity{
+			"john.doe@mail.com": {UserID: "1", Email: "john.doe@mail.com"},
+		},
+		passwords: map[domain.Email]bool{"john.doe@mail.com": true},
+		links:     make(map[externalKey]domain.Email),
+	}
+}
+
+func (s *externalLoginStub) VerifyIDToken(ctx context.Context, idToken, nonce string) (auth.ExternalIdentity, error) {
+	return s.issuer.verify(idToken, nonce)
+}
+
+func (s *externalLoginStub) FindIdentityByExternal(ctx context.Context, issuer, subject string) (auth.Identity, error) {
+	email, ok := s.links[externalKey{issuer, subject}]
+	if !ok {
+		return auth.Identity{}, auth.ErrUserNotFound
+	}
+
+	return s.users[email], nil
+}
+
+func (s *externalLoginStub) CheckEmailExists(ctx context.Context, email domain.Email) (bool, error) {
+	_, ok := s.users[email]
+	return ok, nil
+}
+
+func (s *externalLoginStub) CreateUserByExternalIdentity(ctx context.Context, ext auth.ExternalIdentity) (auth.Identity, error) {
+	id := auth.Identity{UserID: "2", Email: ext.Email}
+	s.users[ext.Email] = id
+	s.links[externalKey{ext.Issuer, ext.Subject}] = ext.Email
+	return id, nil
+}
+
+func (s *externalLoginStub) Authenticate(ctx context.Context, email domain.Email, password domain.Plaintext) error {
+	if !s.passwords[email] || !ciphertext.Compare(password) {
+		return errPasswordMismatch
+	}
+
+	return nil
+}
+
+func (s *externalLoginStub) SaveExternalIdentity(ctx context.Context, email domain.Email, ext auth.ExternalIdentity) error {
+	s.links[externalKey{ext.Issuer, ext.Subject}] = email
+	return nil
+}
+
+func (s *externalLoginStub) FindExternalIdentitiesByEmail(ctx context.Context, email domain.Email) ([]auth.ExternalIdentity, error) {
+	var exts []auth.ExternalIdentity
+	for k, e := range s.links {
+		if e == email {
+			exts = append(exts, auth.ExternalIdentity{Issuer: k.issuer, Subject: k.subject, Email: e})
+		}
+	}
+
+	return exts, nil
+}
+
+func (s *externalLoginStub) HasPassword(ctx context.Context, email domain.Email) (bool, error) {
+	return s.passwords[email], nil
+}
+
+func (s *externalLoginStub) DeleteExternalIdentity(ctx context.Context, email domain.Email, issuer, subject string) error {
+	delete(s.links, externalKey{issuer, subject})
+	return nil
+}
+
+type externalLoginDeviceStub struct {
+	*externalLoginStub
+	enrolled bool
+	devices  map[domain.DeviceFingerprint]bool
+}
+
+func (s *externalLoginDeviceStub) HasSecondFactor(ctx context.Context, email domain.Email) (bool, error) {
+	return s.enrolled, nil
+}
+
+func (s *externalLoginDeviceStub) CreateSecondFactorChallenge(ctx context.Context, email domain.Email, attempt auth.LoginAttempt) (string, error) {
+	return "challenge", nil
+}
+
+func (s *externalLoginDeviceStub) IsKnownDevice(ctx context.Context, email domain.Email, fingerprint domain.DeviceFingerprint) (bool, error) {
+	return s.devices[fingerprint], nil
+}
+
+func (s *externalLoginDeviceStub) WhenSuspiciousLogin(ctx context.Context, email domain.Email, attempt auth.LoginAttempt) error {
+	return auth.ErrChallengeRequired
+}
+
+func (s *externalLoginDeviceStub) SaveKnownDevice(ctx context.Context, email domain.Email, attempt auth.LoginAttempt) error {
+	s.devices[attempt.Fingerprint] = true
+	return nil
+}
+
+func TestLoginExternal(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("new user", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newExternalLoginStub(t)
+		idToken := steps.issuer.issue(t, idTokenClaims{
+			Subject:       "google-1",
+			Nonce:         "nonce",
+			Email:         "jane.doe@mail.com",
+			EmailVerified: true,
+		})
+
+		id, err := auth.LoginExternal(ctx, steps, auth.LoginExternalDto{IDToken: idToken, Nonce: "nonce"})
+		assert.Nil(err)
+		assert.Equal(auth.Identity{UserID: "2", Email: "jane.doe@mail.com"}, id)
+
+		// Login again with the linked identity.
+		id, err = auth.LoginExternal(ctx, steps, auth.LoginExternalDto{IDToken: idToken, Nonce: "nonce"})
+		assert.Nil(err)
+		assert.Equal("2", id.UserID)
+	})
+
+	t.Run("second factor", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := &externalLoginDeviceStub{
+			externalLoginStub: newExternalLoginStub(t),
+			devices:           make(map[domain.DeviceFingerprint]bool),
+		}
+		steps.links[externalKey{fakeIssuerURL, "google-1"}] = "john.doe@mail.com"
+		idToken := steps.issuer.issue(t, idTokenClaims{Subject: "google-1"})
+		dto := auth.LoginExternalDto{IDToken: idToken, DeviceID: "device-1"}
+
+		// The new device is challenged, and refused without a second factor.
+		_, err := auth.LoginExternal(ctx, steps, dto)
+		assert.ErrorIs(err, auth.ErrChallengeRequired)
+		assert.Empty(steps.devices)
+
+		steps.enrolled = true
+		_, err = auth.LoginExternal(ctx, steps, dto)
+		var sfErr *auth.SecondFactorRequiredError
+		assert.True(errors.As(err, &sfErr))
+		assert.Equal(auth.ReasonSuspiciousLogin, sfErr.Reason)
+
+		// The device of a new User is remembered.
+		idToken = steps.issuer.issue(t, idTokenClaims{
+			Subject:       "google-2",
+			Email:         "jane.doe@mail.com",
+			EmailVerified: true,
+		})
+		id, err := auth.LoginExternal(ctx, steps, auth.LoginExternalDto{IDToken: idToken, DeviceID: "device-2"})
+		assert.Nil(err)
+		assert.Equal("2", id.UserID)
+		assert.True(steps.devices[domain.NewDeviceFingerprint("device-2", "")])
+	})
+
+	t.Run("invalid id token", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newExternalLoginStub(t)
+		other := newFakeIssuer(t)
+		idToken := other.issue(t, idTokenClaims{Subject: "google-1", Email: "jane.doe@mail.com", EmailVerified: true})
+
+		_, err := auth.LoginExternal(ctx, steps, auth.LoginExternalDto{IDToken: idToken})
+		assert.ErrorIs(err, errIDTokenInvalid)
+
+		idToken = steps.issuer.issue(t, idTokenClaims{Subject: "google-1", Nonce: "nonce"})
+		_, err = auth.LoginExternal(ctx, steps, auth.LoginExternalDto{IDToken: idToken, Nonce: "other"})
+		assert.ErrorIs(err, errNonceMismatch)
+
+		idToken = steps.issuer.issue(t, idTokenClaims{Subject: "google-1", ExpiresAt: time.Now().Unix()})
+		_, err = auth.LoginExternal(ctx, steps, auth.LoginExternalDto{IDToken: idToken})
+		assert.ErrorIs(err, errIDTokenInvalid)
+
+		_, err = auth.LoginExternal(ctx, steps, auth.LoginExternalDto{})
+		assert.ErrorIs(err, auth.ErrIDTokenRequired)
+	})
+
+	t.Run("existing account requires link", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newExternalLoginStub(t)
+		idToken := steps.issuer.issue(t, idTokenClaims{
+			Subject:       "google-1",
+			Email:         "john.doe@mail.com",
+			EmailVerified: true,
+		})
+
+		_, err := auth.LoginExternal(ctx, steps, auth.LoginExternalDto{IDToken: idToken})
+		var linkErr *auth.LinkRequiredError
+		assert.True(errors.As(err, &linkErr))
+		assert.Equal(domain.Email("john.doe@mail.com"), linkErr.Email)
+		assert.Empty(steps.links)
+	})
+
+	t.Run("unverified email", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newExternalLoginStub(t)
+		for _, email := range []string{"john.doe@mail.com", "jane.doe@mail.com"} {
+			idToken := steps.issuer.issue(t, idTokenClaims{Subject: "google-1", Email: email})
+
+			_, err := auth.LoginExternal(ctx, steps, auth.LoginExternalDto{IDToken: idToken})
+			assert.ErrorIs(err, auth.ErrExternalEmailUnverified)
+		}
+		assert.Empty(steps.links)
+	})
+}
+
+func TestLinkExternal(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newExternalLoginStub(t)
+
+		// The email at the provider does not need to match, since the password
+		// proves the ownership of the account.
+		idToken := steps.issuer.issue(t, idTokenClaims{Subject: "google-1", Email: "john@example.com"})
+		dto := auth.LinkExternalDto{
+			Email:    "john.doe@mail.com",
+			Password: string(password),
+			IDToken:  idToken,
+		}
+		assert.Nil(auth.LinkExternal(ctx, steps, dto))
+		assert.Nil(auth.LinkExternal(ctx, steps, dto))
+
+		id, err := auth.LoginExternal(ctx, steps, auth.LoginExternalDto{IDToken: idToken})
+		assert.Nil(err)
+		assert.Equal("1", id.UserID)
+	})
+
+	t.Run("password mismatch", func(t *testing.T) {
+		steps := newExternalLoginStub(t)
+		err := auth.LinkExternal(ctx, steps, auth.LinkExternalDto{
+			Email:    "john.doe@mail.com",
+			Password: "87654321",
+			IDToken:  steps.issuer.issue(t, idTokenClaims{Subject: "google-1"}),
+		})
+		assert.ErrorIs(t, err, errPasswordMismatch)
+		assert.Empty(t, steps.links)
+	})
+
+	t.Run("linked to another account", func(t *testing.T) {
+		steps := newExternalLoginStub(t)
+		steps.users["jane.doe@mail.com"] = auth.Identity{UserID: "2", Email: "jane.doe@mail.com"}
+		steps.links[externalKey{fakeIssuerURL, "google-1"}] = "jane.doe@mail.com"
+
+		err := auth.LinkExternal(ctx, steps, auth.LinkExternalDto{
+			Email:    "john.doe@mail.com",
+			Password: string(password),
+			IDToken:  steps.issuer.issue(t, idTokenClaims{Subject: "google-1"}),
+		})
+		assert.ErrorIs(t, err, auth.ErrExternalIdentityLinked)
+	})
+}
+
+func TestUnlinkExternal(t *testing.T) {
+	ctx := context.Background()
+	dto := auth.UnlinkExternalDto{
+		Email:   "jane.doe@mail.com",
+		Issuer:  fakeIssuerURL,
+		Subject: "google-1",
+	}
+
+	t.Run("last login method", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newExternalLoginStub(t)
+		steps.links[externalKey{fakeIssuerURL, "google-1"}] = "jane.doe@mail.com"
+		assert.ErrorIs(auth.UnlinkExternal(ctx, steps, dto), auth.ErrLastLoginMethod)
+
+		// Another external identity to login with.
+		steps.links[externalKey{"https://appleid.example.com", "apple-1"}] = "jane.doe@mail.com"
+		assert.Nil(auth.UnlinkExternal(ctx, steps, dto))
+		assert.Len(steps.links, 1)
+	})
+
+	t.Run("with password", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dto := dto
+		dto.Email = "john.doe@mail.com"
+
+		steps := newExternalLoginStub(t)
+		steps.links[externalKey{fakeIssuerURL, "google-1"}] = "john.doe@mail.com"
+		assert.Nil(auth.UnlinkExternal(ctx, steps, dto))
+		assert.Empty(steps.links)
+
+		assert.ErrorIs(auth.UnlinkExternal(ctx, steps, dto), auth.ErrExternalIdentityNotLinked)
+	})
+}
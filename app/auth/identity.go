@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+)
+
+// Identity is the authenticated User returned by the passwordless flows.
+type Identity struct {
+	UserID      string
+	Email       domain.Email
+	PhoneNumber domain.PhoneNumber
+}
+
+// loginIdentity applies the optional steps of loginSecondFactor and
+// loginDevice to an existing User, as in Login. The flows that return an
+// Identity only prove ownership of a phone number or external identity, and
+// must not bypass an enrolled second factor. The steps are keyed by the email,
+// and skipped for Users without one.
+func loginIdentity(ctx context.Context, steps any, id Identity, dto LoginDto) error {
+	if id.Email == "" {
+		return nil
+	}
+
+	attempt, err := newLoginAttempt(ctx, steps, id.Email, dto)
+	if err != nil {
+		return err
+	}
+
+	if err := requireSecondFactor(ctx, steps, id.Email, attempt); err != nil {
+		return err
+	}
+
+	return rememberLogin(ctx, steps, id.Email, attempt)
+}
+
+// rememberIdentity remembers the device of a User created by the login, that
+// cannot have a second factor or known devices yet.
+func rememberIdentity(ctx context.Context, steps any, id Identity, dto LoginDto) error {
+	if id.Email == "" {
+		return nil
+	}
+
+	attempt, err := newLoginAttempt(ctx, steps, id.Email, dto)
+	if err != nil {
+		return err
+	}
+
+	return rememberLogin(ctx, steps, id.Email, attempt)
+}
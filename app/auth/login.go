@@ -2,18 +2,140 @@ package auth
 
 import (
 	"context"
+	"errors"
+	"net"
+	"time"
 
 	"github.com/alextanhongpin/go-service-oriented-package/domain"
 )
 
+// Login errors.
+var (
+	// ErrSecondFactorRequired is returned by Login when the password matches,
+	// but the User still needs to complete a second factor.
+	ErrSecondFactorRequired = errors.New("auth: second factor required")
+
+	// ErrLockedOut is returned by Login when there are too many failed logins
+	// for the email or IP address.
+	ErrLockedOut = errors.New("auth: locked out")
+
+	// ErrInvalidIPAddress is returned by Login when the optional IP address in
+	// LoginDto cannot be parsed.
+	ErrInvalidIPAddress = errors.New("auth: invalid ip address")
+)
+
+// ErrUserNotFound should be returned by steps that look up a User that does not
+// exist. Flows use it to respond the same way as when the User exists.
+var ErrUserNotFound = errors.New("auth: user not found")
+
+// Reasons for SecondFactorRequiredError.
+const (
+	ReasonSecondFactor    = "second_factor"
+	ReasonSuspiciousLogin = "suspicious_login"
+)
+
+// SecondFactorRequiredError carries the challenge that needs to be completed
+// with LoginTOTP or LoginRecoveryCode.
+type SecondFactorRequiredError struct {
+	Challenge string
+	Reason    string
+}
+
+func (e *SecondFactorRequiredError) Error() string {
+	return ErrSecondFactorRequired.Error()
+}
+
+func (e *SecondFactorRequiredError) Unwrap() error {
+	return ErrSecondFactorRequired
+}
+
+// LockedOutError carries the time when Login can be retried.
+type LockedOutError struct {
+	Until time.Time
+}
+
+func (e *LockedOutError) Error() string {
+	return ErrLockedOut.Error()
+}
+
+func (e *LockedOutError) Unwrap() error {
+	return ErrLockedOut
+}
+
+// RetryAfter returns the wait time before Login can be retried.
+func (e *LockedOutError) RetryAfter() time.Duration {
+	return time.Until(e.Until)
+}
+
 type login interface {
+	// 1. Load the ciphertext of the User. Return ErrUserNotFound when the User
+	// does not exist, so that the password mismatch is reported instead.
 	FindEncryptedPasswordByEmail(ctx context.Context, email domain.Email) (domain.Ciphertext, error)
+
+	// 2. What error to return if the password does not match, or the User does
+	// not exist?
 	WhenPasswordMatch(ctx context.Context, match bool) error
 }
 
+// Optional step for Login to upgrade ciphertexts that are encrypted with
+// outdated parameters, without forcing the User to reset the password.
+type loginRehash interface {
+	// 1. Replace the ciphertext of the User. Only called after the password
+	// matches.
+	UpdateRehashedPassword(ctx context.Context, email domain.Email, ciphertext domain.Ciphertext) error
+}
+
+// Optional steps for Login when the User may have a second factor enrolled.
+type loginSecondFactor interface {
+	// 1. Checks if the User has a second factor enrolled.
+	HasSecondFactor(ctx context.Context, email domain.Email) (bool, error)
+
+	// 2. Create a short-lived challenge with the email as the subject, and the
+	// attempt, e.g. the fingerprint and location as token claims. The challenge
+	// is verified in LoginTOTP or LoginRecoveryCode, which remember the
+	// attempt.
+	CreateSecondFactorChallenge(ctx context.Context, email domain.Email, attempt LoginAttempt) (string, error)
+}
+
+// Optional steps for Login to refuse or flag logins from unverified accounts.
+type loginEmailVerification interface {
+	// 1. Checks if the email is verified. Only called after the password
+	// matches.
+	IsEmailVerified(ctx context.Context, email domain.Email) (bool, error)
+
+	// 2. What error to return if the email is not verified? Return
+	// ErrEmailNotVerified to refuse, or nil after flagging the login.
+	WhenEmailVerified(ctx context.Context, verified bool) error
+}
+
+// Optional steps for Login to lock out an email or IP address after too many
+// failed logins. The IP address steps are skipped when LoginDto has no IP
+// address.
+type loginLockout interface {
+	// 1. The threshold and lockout duration.
+	LockoutPolicy() domain.LockoutPolicy
+
+	// 2. Load the failure counters. The zero value should be returned when
+	// there are no failures.
+	FindFailedLoginsByEmail(ctx context.Context, email domain.Email) (domain.FailedLogins, error)
+	FindFailedLoginsByIP(ctx context.Context, ip string) (domain.FailedLogins, error)
+
+	// 3a. Increment the failure counters, and set the last failed time to now.
+	IncrementFailedLoginsByEmail(ctx context.Context, email domain.Email) error
+	IncrementFailedLoginsByIP(ctx context.Context, ip string) error
+
+	// 3b. Reset the email counter when the login completes, and the IP
+	// address counter when it is older than the LockoutPolicy.Window.
+	ResetFailedLoginsByEmail(ctx context.Context, email domain.Email) error
+	ResetFailedLoginsByIP(ctx context.Context, ip string) error
+}
+
 type LoginDto struct {
-	Email    string
-	Password string
+	Email     string
+	Password  string
+	IPAddress string `example:"203.0.113.1" desc:"Optional client IP address"`
+	UserAgent string `desc:"Optional client user agent"`
+	DeviceID  string `desc:"Optional identifier of the device or browser, e.g. from a cookie"`
 }
 
 func (d LoginDto) Validate() error {
@@ -25,6 +147,10 @@ func (d LoginDto) Validate() error {
 		return err
 	}
 
+	if d.IPAddress != "" && net.ParseIP(d.IPAddress) == nil {
+		return ErrInvalidIPAddress
+	}
+
 	return nil
 }
 
@@ -35,15 +161,185 @@ func Login(ctx context.Context, steps login, dto LoginDto) error {
 
 	email := domain.Email(dto.Email)
 	password := domain.Plaintext(dto.Password)
+	ip := dto.IPAddress
 
+	lockout, hasLockout := steps.(loginLockout)
+	if hasLockout {
+		if err := checkLockout(ctx, lockout, email, ip); err != nil {
+			return err
+		}
+	}
+
+	found := true
 	ciphertext, err := steps.FindEncryptedPasswordByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		// Spend the same time comparing, so that the response time does not
+		// reveal that the User does not exist.
+		found = false
+		ciphertext = domain.DummyCiphertext()
+	} else if err != nil {
+		return err
+	}
+
+	match := ciphertext.Compare(password) && found
+	if hasLockout && !match {
+		if err := countFailedLogins(ctx, lockout, email, ip, match); err != nil {
+			return err
+		}
+	}
+
+	if err := steps.WhenPasswordMatch(ctx, match); err != nil {
+		return err
+	}
+
+	// A mismatch allowed by WhenPasswordMatch must not replace the ciphertext
+	// with the guessed password.
+	if s, ok := steps.(loginRehash); ok && match && ciphertext.NeedsRehash() {
+		if err := rehash(ctx, s, email, password); err != nil {
+			return err
+		}
+	}
+
+	if s, ok := steps.(loginEmailVerification); ok {
+		verified, err := s.IsEmailVerified(ctx, email)
+		if err != nil {
+			return err
+		}
+
+		if err := s.WhenEmailVerified(ctx, verified); err != nil {
+			return err
+		}
+	}
+
+	attempt, err := newLoginAttempt(ctx, steps, email, dto)
+	if err != nil {
+		return err
+	}
+
+	if err := requireSecondFactor(ctx, steps, email, attempt); err != nil {
+		return err
+	}
+
+	// The email counter is only reset when the login completes, since the
+	// second factor is guessed against the same counter, and is reset by
+	// LoginTOTP or LoginRecoveryCode instead.
+	if hasLockout {
+		if err := lockout.ResetFailedLoginsByEmail(ctx, email); err != nil {
+			return err
+		}
+	}
+
+	return rememberLogin(ctx, steps, email, attempt)
+}
+
+// requireSecondFactor returns a *SecondFactorRequiredError when the User has a
+// second factor enrolled. The reason is ReasonSuspiciousLogin when the steps
+// require a challenge for the attempt, and ErrChallengeRequired is returned
+// instead when the User has no second factor.
+func requireSecondFactor(ctx context.Context, steps any, email domain.Email, attempt LoginAttempt) error {
+	suspicious, err := checkSuspiciousLogin(ctx, steps, email, attempt)
 	if err != nil {
 		return err
 	}
 
-	if err := steps.WhenPasswordMatch(ctx, ciphertext.Compare(password)); err != nil {
+	s, ok := steps.(loginSecondFactor)
+	enrolled := false
+	if ok {
+		enrolled, err = s.HasSecondFactor(ctx, email)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Users without a second factor cannot complete the challenge, so the
+	// login is refused when the steps require one.
+	if !enrolled {
+		if suspicious {
+			return ErrChallengeRequired
+		}
+
+		return nil
+	}
+
+	challenge, err := s.CreateSecondFactorChallenge(ctx, email, attempt)
+	if err != nil {
 		return err
 	}
 
+	reason := ReasonSecondFactor
+	if suspicious {
+		reason = ReasonSuspiciousLogin
+	}
+
+	return &SecondFactorRequiredError{
+		Challenge: challenge,
+		Reason:    reason,
+	}
+}
+
+func rehash(ctx context.Context, steps loginRehash, email domain.Email, password domain.Plaintext) error {
+	ciphertext, err := password.Encrypt()
+	if err != nil {
+		return err
+	}
+
+	return steps.UpdateRehashedPassword(ctx, email, ciphertext)
+}
+
+func checkLockout(ctx context.Context, steps loginLockout, email domain.Email, ip string) error {
+	policy := steps.LockoutPolicy()
+
+	failed, err := steps.FindFailedLoginsByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	until := policy.LockedUntil(failed)
+
+	if ip != "" {
+		failed, err := steps.FindFailedLoginsByIP(ctx, ip)
+		if err != nil {
+			return err
+		}
+
+		if t := policy.LockedUntil(failed); t.After(until) {
+			until = t
+		}
+	}
+
+	if time.Now().Before(until) {
+		return &LockedOutError{Until: until}
+	}
+
 	return nil
 }
+
+// countFailedLogins resets the email counter on success. The IP address
+// counter is not reset, since the attacker could login to an account they own
+// between guesses, and is forgotten after the LockoutPolicy.Window instead.
+func countFailedLogins(ctx context.Context, steps loginLockout, email domain.Email, ip string, match bool) error {
+	if match {
+		return steps.ResetFailedLoginsByEmail(ctx, email)
+	}
+
+	if err := steps.IncrementFailedLoginsByEmail(ctx, email); err != nil {
+		return err
+	}
+
+	if ip == "" {
+		return nil
+	}
+
+	failed, err := steps.FindFailedLoginsByIP(ctx, ip)
+	if err != nil {
+		return err
+	}
+
+	if steps.LockoutPolicy().Expired(failed, time.Now()) {
+		if err := steps.ResetFailedLoginsByIP(ctx, ip); err != nil {
+			return err
+		}
+	}
+
+	return steps.IncrementFailedLoginsByIP(ctx, ip)
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+)
+
+// ErrChallengeRequired can be returned by WhenSuspiciousLogin to require a
+// challenge, that is created with CreateSecondFactorChallenge. Login returns it
+// when the steps cannot create challenges, or the User has no second factor.
+var ErrChallengeRequired = errors.New("auth: challenge required")
+
+// LoginAttempt is the client metadata of a login.
+type LoginAttempt struct {
+	Fingerprint domain.DeviceFingerprint
+	IPAddress   string
+	UserAgent   string
+	Location    domain.GeoLocation // Zero when unknown.
+	At          time.Time
+
+	KnownDevice      bool
+	ImpossibleTravel bool
+}
+
+// Suspicious returns true if the login is from a new device, or too far from
+// the last login.
+func (a LoginAttempt) Suspicious() bool {
+	return !a.KnownDevice || a.ImpossibleTravel
+}
+
+// Optional steps for Login to detect logins from new devices. The device is
+// remembered when Login completes, or after LoginTOTP or LoginRecoveryCode
+// when a challenge is required.
+type loginDevice interface {
+	// 1. Checks if the device is known for the User.
+	IsKnownDevice(ctx context.Context, email domain.Email, fingerprint domain.DeviceFingerprint) (bool, error)
+
+	// 2. Called when the login is suspicious. Notify the User and return nil,
+	// or return ErrChallengeRequired to require a challenge. The login is
+	// refused with ErrChallengeRequired when the User has no second factor.
+	WhenSuspiciousLogin(ctx context.Context, email domain.Email, attempt LoginAttempt) error
+
+	// 3. Remember the device.
+	SaveKnownDevice(ctx context.Context, email domain.Email, attempt LoginAttempt) error
+}
+
+// Optional steps for Login to detect impossible travel between logins, that
+// is handled by WhenSuspiciousLogin. The steps are skipped when LoginDto has
+// no IP address.
+type loginGeoVelocity interface {
+	// 1. Locate the IP address, e.g. with a domain.GeoTable.
+	GeoLocator() domain.GeoLocator
+
+	// 2. The maximum travel speed, e.g. domain.DefaultGeoVelocityPolicy.
+	GeoVelocityPolicy() domain.GeoVelocityPolicy
+
+	// 3. Load the last completed login. The zero value should be returned when
+	// there is none.
+	FindLastLogin(ctx context.Context, email domain.Email) (LoginAttempt, error)
+
+	// 4. Remember the login.
+	SaveLastLogin(ctx context.Context, email domain.Email, attempt LoginAttempt) error
+}
+
+// Optional steps for LoginTOTP and LoginRecoveryCode to remember the attempt
+// carried by the challenge, like Login does with the steps of loginDevice and
+// loginGeoVelocity.
+type rememberDevice interface {
+	SaveKnownDevice(ctx context.Context, email domain.Email, attempt LoginAttempt) error
+}
+
+type rememberLocation interface {
+	SaveLastLogin(ctx context.Context, email domain.Email, attempt LoginAttempt) error
+}
+
+func newLoginAttempt(ctx context.Context, steps any, email domain.Email, dto LoginDto) (LoginAttempt, error) {
+	attempt := LoginAttempt{
+		Fingerprint: domain.NewDeviceFingerprint(dto.DeviceID, dto.UserAgent),
+		IPAddress:   dto.IPAddress,
+		UserAgent:   dto.UserAgent,
+		At:          time.Now(),
+		KnownDevice: true,
+	}
+
+	if s, ok := steps.(loginDevice); ok {
+		known, err := s.IsKnownDevice(ctx, email, attempt.Fingerprint)
+		if err != nil {
+			return LoginAttempt{}, err
+		}
+
+		attempt.KnownDevice = known
+	}
+
+	if s, ok := steps.(loginGeoVelocity); ok && dto.IPAddress != "" {
+		loc, err := s.GeoLocator().Locate(ctx, dto.IPAddress)
+		if errors.Is(err, domain.ErrLocationNotFound) {
+			return attempt, nil
+		}
+
+		if err != nil {
+			return LoginAttempt{}, err
+		}
+
+		last, err := s.FindLastLogin(ctx, email)
+		if err != nil {
+			return LoginAttempt{}, err
+		}
+
+		attempt.Location = loc
+		attempt.ImpossibleTravel = !last.At.IsZero() &&
+			s.GeoVelocityPolicy().ImpossibleTravel(last.Location, loc, attempt.At.Sub(last.At))
+	}
+
+	return attempt, nil
+}
+
+// checkSuspiciousLogin returns true when the steps require a challenge for the
+// attempt.
+func checkSuspiciousLogin(ctx context.Context, steps any, email domain.Email, attempt LoginAttempt) (bool, error) {
+	s, ok := steps.(loginDevice)
+	if !ok || !attempt.Suspicious() {
+		return false, nil
+	}
+
+	err := s.WhenSuspiciousLogin(ctx, email, attempt)
+	if errors.Is(err, ErrChallengeRequired) {
+		return true, nil
+	}
+
+	return false, err
+}
+
+// rememberLogin remembers the device and location of a completed login.
+func rememberLogin(ctx context.Context, steps any, email domain.Email, attempt LoginAttempt) error {
+	if s, ok := steps.(rememberDevice); ok && !attempt.KnownDevice {
+		if err := s.SaveKnownDevice(ctx, email, attempt); err != nil {
+			return err
+		}
+	}
+
+	if s, ok := steps.(rememberLocation); ok && !attempt.Location.IsZero() {
+		return s.SaveLastLogin(ctx, email, attempt)
+	}
+
+	return nil
+}
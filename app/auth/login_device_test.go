@@ -0,0 +1,232 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/auth"
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	kualaLumpur = domain.GeoLocation{Country: "MY", Latitude: 3.139, Longitude: 101.6869}
+	london      = domain.GeoLocation{Country: "GB", Latitude: 51.5072, Longitude: -0.1276}
+)
+
+type loginDeviceStub struct {
+	loginSecondFactorStub
+
+	challenge bool
+	devices   map[domain.DeviceFingerprint]bool
+	notified  []auth.LoginAttempt
+	lastLogin auth.LoginAttempt
+}
+
+func newLoginDeviceStub(challenge bool) *loginDeviceStub {
+	return &loginDeviceStub{
+		loginSecondFactorStub: loginSecondFactorStub{
+			loginStub: loginStub{ciphertext: ciphertext},
+			challenge: "challenge",
+		},
+		challenge: challenge,
+		devices:   make(map[domain.DeviceFingerprint]bool),
+	}
+}
+
+func (s *loginDeviceStub) IsKnownDevice(ctx context.Context, email domain.Email, fingerprint domain.DeviceFingerprint) (bool, error) {
+	return s.devices[fingerprint], nil
+}
+
+func (s *loginDeviceStub) WhenSuspiciousLogin(ctx context.Context, email domain.Email, attempt auth.LoginAttempt) error {
+	s.notified = append(s.notified, attempt)
+	if s.challenge {
+		return auth.ErrChallengeRequired
+	}
+
+	return nil
+}
+
+func (s *loginDeviceStub) SaveKnownDevice(ctx context.Context, email domain.Email, attempt auth.LoginAttempt) error {
+	s.devices[attempt.Fingerprint] = true
+	return nil
+}
+
+func (s *loginDeviceStub) GeoLocator() domain.GeoLocator {
+	return domain.GeoTable{
+		"203.0.113.0/24":  kualaLumpur,
+		"198.51.100.0/24": london,
+	}
+}
+
+func (s *loginDeviceStub) GeoVelocityPolicy() domain.GeoVelocityPolicy {
+	return domain.DefaultGeoVelocityPolicy
+}
+
+func (s *loginDeviceStub) FindLastLogin(ctx context.Context, email domain.Email) (auth.LoginAttempt, error) {
+	return s.lastLogin, nil
+}
+
+func (s *loginDeviceStub) SaveLastLogin(ctx context.Context, email domain.Email, attempt auth.LoginAttempt) error {
+	s.lastLogin = attempt
+	return nil
+}
+
+// loginChallengeStub challenges every login, but cannot create challenges.
+type loginChallengeStub struct {
+	loginStub
+}
+
+func (s *loginChallengeStub) IsKnownDevice(ctx context.Context, email domain.Email, fingerprint domain.DeviceFingerprint) (bool, error) {
+	return false, nil
+}
+
+func (s *loginChallengeStub) WhenSuspiciousLogin(ctx context.Context, email domain.Email, attempt auth.LoginAttempt) error {
+	return auth.ErrChallengeRequired
+}
+
+func (s *loginChallengeStub) SaveKnownDevice(ctx context.Context, email domain.Email, attempt auth.LoginAttempt) error {
+	return nil
+}
+
+type loginTOTPDeviceStub struct {
+	*loginTOTPStub
+	*loginDeviceStub
+}
+
+func TestLoginDevice(t *testing.T) {
+	ctx := context.Background()
+	dto := auth.LoginDto{
+		Email:     "john.doe@mail.com",
+		Password:  string(password),
+		IPAddress: "203.0.113.1",
+		UserAgent: "Mozilla/5.0 Chrome/118.0.0.0",
+		DeviceID:  "device-1",
+	}
+
+	t.Run("notify new device", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newLoginDeviceStub(false)
+		assert.Nil(auth.Login(ctx, steps, dto))
+		assert.Len(steps.notified, 1)
+		assert.False(steps.notified[0].KnownDevice)
+		assert.Equal(dto.UserAgent, steps.notified[0].UserAgent)
+		assert.Equal(kualaLumpur, steps.lastLogin.Location)
+
+		// The device is remembered.
+		assert.Nil(auth.Login(ctx, steps, dto))
+		assert.Len(steps.notified, 1)
+
+		dto := dto
+		dto.DeviceID = "device-2"
+		assert.Nil(auth.Login(ctx, steps, dto))
+		assert.Len(steps.notified, 2)
+	})
+
+	t.Run("challenge new device", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newLoginDeviceStub(true)
+		steps.enrolled = true
+		err := auth.Login(ctx, steps, dto)
+
+		var sfErr *auth.SecondFactorRequiredError
+		assert.True(errors.As(err, &sfErr))
+		assert.Equal("challenge", sfErr.Challenge)
+		assert.Equal(auth.ReasonSuspiciousLogin, sfErr.Reason)
+
+		// The device is not remembered until the challenge is completed.
+		assert.Empty(steps.devices)
+		assert.True(steps.lastLogin.At.IsZero())
+
+		secret, err := domain.NewTOTPSecret()
+		assert.Nil(err)
+
+		code, err := secret.Code(time.Now())
+		assert.Nil(err)
+
+		totp := &loginTOTPDeviceStub{
+			loginTOTPStub:   &loginTOTPStub{secret: secret, attempt: steps.attempt},
+			loginDeviceStub: steps,
+		}
+		assert.Nil(auth.LoginTOTP(ctx, totp, auth.LoginTOTPDto{
+			Challenge: sfErr.Challenge,
+			Code:      string(code),
+		}))
+		assert.True(steps.devices[steps.attempt.Fingerprint])
+		assert.Equal(kualaLumpur, steps.lastLogin.Location)
+
+		// The device is known on the next login.
+		err = auth.Login(ctx, steps, dto)
+		assert.True(errors.As(err, &sfErr))
+		assert.Equal(auth.ReasonSecondFactor, sfErr.Reason)
+		assert.Len(steps.notified, 1)
+	})
+
+	t.Run("challenge without second factor", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newLoginDeviceStub(true)
+		assert.ErrorIs(auth.Login(ctx, steps, dto), auth.ErrChallengeRequired)
+		assert.Len(steps.notified, 1)
+
+		// The device is not remembered.
+		assert.Empty(steps.devices)
+		assert.True(steps.lastLogin.At.IsZero())
+	})
+
+	t.Run("challenge without second factor steps", func(t *testing.T) {
+		steps := &loginChallengeStub{loginStub: loginStub{ciphertext: ciphertext}}
+		assert.ErrorIs(t, auth.Login(ctx, steps, dto), auth.ErrChallengeRequired)
+	})
+
+	t.Run("impossible travel", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newLoginDeviceStub(false)
+		assert.Nil(auth.Login(ctx, steps, dto))
+		assert.Len(steps.notified, 1)
+
+		// Login from London an hour after Kuala Lumpur, on a known device.
+		steps.lastLogin.At = time.Now().Add(-time.Hour)
+		dto := dto
+		dto.IPAddress = "198.51.100.1"
+		assert.Nil(auth.Login(ctx, steps, dto))
+		assert.Len(steps.notified, 2)
+		assert.True(steps.notified[1].KnownDevice)
+		assert.True(steps.notified[1].ImpossibleTravel)
+		assert.Equal(london, steps.notified[1].Location)
+
+		// Plausible after a long flight.
+		steps.lastLogin.At = time.Now().Add(-14 * time.Hour)
+		steps.lastLogin.Location = kualaLumpur
+		assert.Nil(auth.Login(ctx, steps, dto))
+		assert.Len(steps.notified, 2)
+	})
+
+	t.Run("unknown location", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newLoginDeviceStub(false)
+		dto := dto
+		dto.IPAddress = "192.0.2.1"
+		assert.Nil(auth.Login(ctx, steps, dto))
+		assert.True(steps.lastLogin.At.IsZero())
+	})
+
+	t.Run("second factor", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newLoginDeviceStub(false)
+		steps.enrolled = true
+
+		err := auth.Login(ctx, steps, dto)
+		var sfErr *auth.SecondFactorRequiredError
+		assert.True(errors.As(err, &sfErr))
+		assert.Equal(auth.ReasonSecondFactor, sfErr.Reason)
+		assert.Len(steps.notified, 1)
+	})
+}
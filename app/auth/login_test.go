@@ -0,0 +1,431 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/auth"
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	wantErr             = errors.New("want")
+	errPasswordMismatch = errors.New("password mismatch")
+)
+
+const password = domain.Plaintext("12345678")
+
+var ciphertext = func() domain.Ciphertext {
+	c, err := password.Encrypt()
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}()
+
+type loginStub struct {
+	ciphertext domain.Ciphertext
+	findErr    error
+}
+
+func (s *loginStub) FindEncryptedPasswordByEmail(ctx context.Context, email domain.Email) (domain.Ciphertext, error) {
+	return s.ciphertext, s.findErr
+}
+
+func (s *loginStub) WhenPasswordMatch(ctx context.Context, match bool) error {
+	if !match {
+		return errPasswordMismatch
+	}
+
+	return nil
+}
+
+type loginSecondFactorStub struct {
+	loginStub
+	enrolled  bool
+	challenge string
+	attempt   auth.LoginAttempt
+}
+
+func (s *loginSecondFactorStub) HasSecondFactor(ctx context.Context, email domain.Email) (bool, error) {
+	return s.enrolled, nil
+}
+
+func (s *loginSecondFactorStub) CreateSecondFactorChallenge(ctx context.Context, email domain.Email, attempt auth.LoginAttempt) (string, error) {
+	s.attempt = attempt
+	return s.challenge, nil
+}
+
+type loginEmailVerificationStub struct {
+	loginStub
+	verified bool
+	flagged  bool
+}
+
+func (s *loginEmailVerificationStub) IsEmailVerified(ctx context.Context, email domain.Email) (bool, error) {
+	return s.verified, nil
+}
+
+func (s *loginEmailVerificationStub) WhenEmailVerified(ctx context.Context, verified bool) error {
+	s.flagged = !verified
+	return nil
+}
+
+type loginRefuseUnverifiedStub struct {
+	loginEmailVerificationStub
+}
+
+func (s *loginRefuseUnverifiedStub) WhenEmailVerified(ctx context.Context, verified bool) error {
+	if !verified {
+		return auth.ErrEmailNotVerified
+	}
+
+	return nil
+}
+
+func TestLogin(t *testing.T) {
+	dto := auth.LoginDto{
+		Email:    "john.doe@mail.com",
+		Password: string(password),
+	}
+
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		err := auth.Login(ctx, &loginStub{ciphertext: ciphertext}, dto)
+		assert.Nil(t, err)
+	})
+
+	t.Run("validation error", func(t *testing.T) {
+		err := auth.Login(ctx, &loginStub{ciphertext: ciphertext}, auth.LoginDto{})
+		assert.ErrorIs(t, err, domain.ErrEmailInvalid)
+	})
+
+	t.Run("find error", func(t *testing.T) {
+		err := auth.Login(ctx, &loginStub{findErr: wantErr}, dto)
+		assert.ErrorIs(t, err, wantErr)
+	})
+
+	t.Run("password mismatch", func(t *testing.T) {
+		dto := dto
+		dto.Password = "87654321"
+
+		err := auth.Login(ctx, &loginStub{ciphertext: ciphertext}, dto)
+		assert.ErrorIs(t, err, errPasswordMismatch)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		steps := &loginStub{findErr: fmt.Errorf("%w: john.doe@mail.com", auth.ErrUserNotFound)}
+
+		start := time.Now()
+		err := auth.Login(ctx, steps, dto)
+		assert.ErrorIs(t, err, errPasswordMismatch)
+		assert.NotErrorIs(t, err, auth.ErrUserNotFound)
+
+		// The dummy ciphertext is compared.
+		assert.Greater(t, time.Since(start), time.Millisecond)
+	})
+}
+
+func TestLoginEmailVerification(t *testing.T) {
+	dto := auth.LoginDto{
+		Email:    "john.doe@mail.com",
+		Password: string(password),
+	}
+
+	ctx := context.Background()
+
+	t.Run("verified", func(t *testing.T) {
+		steps := &loginRefuseUnverifiedStub{}
+		steps.ciphertext = ciphertext
+		steps.verified = true
+		assert.Nil(t, auth.Login(ctx, steps, dto))
+	})
+
+	t.Run("refuse unverified", func(t *testing.T) {
+		steps := &loginRefuseUnverifiedStub{}
+		steps.ciphertext = ciphertext
+		assert.ErrorIs(t, auth.Login(ctx, steps, dto), auth.ErrEmailNotVerified)
+	})
+
+	t.Run("flag unverified", func(t *testing.T) {
+		steps := &loginEmailVerificationStub{loginStub: loginStub{ciphertext: ciphertext}}
+		assert.Nil(t, auth.Login(ctx, steps, dto))
+		assert.True(t, steps.flagged)
+	})
+
+	t.Run("password mismatch", func(t *testing.T) {
+		steps := &loginRefuseUnverifiedStub{}
+		steps.ciphertext = ciphertext
+
+		dto := dto
+		dto.Password = "87654321"
+		assert.ErrorIs(t, auth.Login(ctx, steps, dto), errPasswordMismatch)
+	})
+}
+
+func TestLoginSecondFactor(t *testing.T) {
+	dto := auth.LoginDto{
+		Email:    "john.doe@mail.com",
+		Password: string(password),
+	}
+
+	ctx := context.Background()
+
+	t.Run("not enrolled", func(t *testing.T) {
+		steps := &loginSecondFactorStub{loginStub: loginStub{ciphertext: ciphertext}}
+		assert.Nil(t, auth.Login(ctx, steps, dto))
+	})
+
+	t.Run("enrolled", func(t *testing.T) {
+		steps := &loginSecondFactorStub{
+			loginStub: loginStub{ciphertext: ciphertext},
+			enrolled:  true,
+			challenge: "challenge",
+		}
+
+		err := auth.Login(ctx, steps, dto)
+		assert.ErrorIs(t, err, auth.ErrSecondFactorRequired)
+
+		var sfErr *auth.SecondFactorRequiredError
+		assert.True(t, errors.As(err, &sfErr))
+		assert.Equal(t, "challenge", sfErr.Challenge)
+	})
+
+	t.Run("password mismatch", func(t *testing.T) {
+		steps := &loginSecondFactorStub{
+			loginStub: loginStub{ciphertext: ciphertext},
+			enrolled:  true,
+		}
+
+		dto := dto
+		dto.Password = "87654321"
+		assert.ErrorIs(t, auth.Login(ctx, steps, dto), errPasswordMismatch)
+	})
+}
+
+type loginLockoutStub struct {
+	loginStub
+	byEmail map[domain.Email]domain.FailedLogins
+	byIP    map[string]domain.FailedLogins
+}
+
+func newLoginLockoutStub() *loginLockoutStub {
+	return &loginLockoutStub{
+		loginStub: loginStub{ciphertext: ciphertext},
+		byEmail:   make(map[domain.Email]domain.FailedLogins),
+		byIP:      make(map[string]domain.FailedLogins),
+	}
+}
+
+func (s *loginLockoutStub) LockoutPolicy() domain.LockoutPolicy {
+	return domain.LockoutPolicy{
+		Threshold:   3,
+		Duration:    time.Minute,
+		MaxDuration: time.Hour,
+		Window:      2 * time.Hour,
+	}
+}
+
+func (s *loginLockoutStub) FindFailedLoginsByEmail(ctx context.Context, email domain.Email) (domain.FailedLogins, error) {
+	return s.byEmail[email], nil
+}
+
+func (s *loginLockoutStub) FindFailedLoginsByIP(ctx context.Context, ip string) (domain.FailedLogins, error) {
+	return s.byIP[ip], nil
+}
+
+func (s *loginLockoutStub) IncrementFailedLoginsByEmail(ctx context.Context, email domain.Email) error {
+	f := s.byEmail[email]
+	s.byEmail[email] = domain.FailedLogins{Count: f.Count + 1, LastFailedAt: time.Now()}
+	return nil
+}
+
+func (s *loginLockoutStub) IncrementFailedLoginsByIP(ctx context.Context, ip string) error {
+	f := s.byIP[ip]
+	s.byIP[ip] = domain.FailedLogins{Count: f.Count + 1, LastFailedAt: time.Now()}
+	return nil
+}
+
+func (s *loginLockoutStub) ResetFailedLoginsByEmail(ctx context.Context, email domain.Email) error {
+	delete(s.byEmail, email)
+	return nil
+}
+
+func (s *loginLockoutStub) ResetFailedLoginsByIP(ctx context.Context, ip string) error {
+	delete(s.byIP, ip)
+	return nil
+}
+
+func TestLoginLockout(t *testing.T) {
+	dto := auth.LoginDto{
+		Email:     "john.doe@mail.com",
+		Password:  "87654321",
+		IPAddress: "203.0.113.1",
+	}
+
+	ctx := context.Background()
+
+	t.Run("invalid ip address", func(t *testing.T) {
+		dto := dto
+		dto.IPAddress = "localhost"
+		assert.ErrorIs(t, auth.Login(ctx, newLoginLockoutStub(), dto), auth.ErrInvalidIPAddress)
+	})
+
+	t.Run("locked out by email", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newLoginLockoutStub()
+		for i := 0; i < 3; i++ {
+			dto := dto
+			dto.IPAddress = ""
+			assert.ErrorIs(auth.Login(ctx, steps, dto), errPasswordMismatch)
+		}
+		assert.Len(steps.byIP, 0)
+
+		// The correct password is rejected while locked out.
+		dto := dto
+		dto.Password = string(password)
+		err := auth.Login(ctx, steps, dto)
+		assert.ErrorIs(err, auth.ErrLockedOut)
+
+		var lockedErr *auth.LockedOutError
+		assert.True(errors.As(err, &lockedErr))
+		assert.InDelta(time.Minute, lockedErr.RetryAfter(), float64(time.Second))
+	})
+
+	t.Run("locked out by ip", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newLoginLockoutStub()
+		for i := 0; i < 3; i++ {
+			dto := dto
+			dto.Email = fmt.Sprintf("john.doe+%d@mail.com", i)
+			assert.ErrorIs(auth.Login(ctx, steps, dto), errPasswordMismatch)
+		}
+
+		assert.ErrorIs(auth.Login(ctx, steps, dto), auth.ErrLockedOut)
+	})
+
+	t.Run("reset on success", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newLoginLockoutStub()
+		assert.ErrorIs(auth.Login(ctx, steps, dto), errPasswordMismatch)
+		assert.Equal(1, steps.byEmail[domain.Email(dto.Email)].Count)
+		assert.Equal(1, steps.byIP[dto.IPAddress].Count)
+
+		dto := dto
+		dto.Password = string(password)
+		assert.Nil(auth.Login(ctx, steps, dto))
+		assert.Len(steps.byEmail, 0)
+
+		// The IP address counter is not reset by a successful login.
+		assert.Equal(1, steps.byIP[dto.IPAddress].Count)
+	})
+
+	t.Run("ip not reset by own account", func(t *testing.T) {
+		assert := assert.New(t)
+
+		// The attacker logins to an account they own between guesses.
+		own := dto
+		own.Email = "attacker@mail.com"
+		own.Password = string(password)
+
+		steps := newLoginLockoutStub()
+		for i := 0; i < 3; i++ {
+			assert.Nil(auth.Login(ctx, steps, own))
+			assert.ErrorIs(auth.Login(ctx, steps, dto), errPasswordMismatch)
+		}
+
+		assert.ErrorIs(auth.Login(ctx, steps, own), auth.ErrLockedOut)
+	})
+
+	t.Run("ip expired", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newLoginLockoutStub()
+		steps.byIP[dto.IPAddress] = domain.FailedLogins{
+			Count:        10,
+			LastFailedAt: time.Now().Add(-3 * time.Hour),
+		}
+
+		assert.ErrorIs(auth.Login(ctx, steps, dto), errPasswordMismatch)
+		assert.Equal(1, steps.byIP[dto.IPAddress].Count)
+	})
+}
+
+type loginRehashStub struct {
+	loginStub
+	rehashed domain.Ciphertext
+}
+
+// loginRehashFlagStub allows mismatched passwords, e.g. to flag them instead.
+type loginRehashFlagStub struct {
+	*loginRehashStub
+}
+
+func (s *loginRehashFlagStub) WhenPasswordMatch(ctx context.Context, match bool) error {
+	return nil
+}
+
+func (s *loginRehashStub) UpdateRehashedPassword(ctx context.Context, email domain.Email, ciphertext domain.Ciphertext) error {
+	s.rehashed = ciphertext
+	return nil
+}
+
+func TestLoginRehash(t *testing.T) {
+	dto := auth.LoginDto{
+		Email:    "john.doe@mail.com",
+		Password: string(password),
+	}
+
+	ctx := context.Background()
+
+	t.Run("up to date", func(t *testing.T) {
+		steps := &loginRehashStub{loginStub: loginStub{ciphertext: ciphertext}}
+		assert.Nil(t, auth.Login(ctx, steps, dto))
+		assert.Empty(t, steps.rehashed)
+	})
+
+	t.Run("outdated", func(t *testing.T) {
+		assert := assert.New(t)
+
+		t.Cleanup(func() {
+			domain.RegisterPasswordHasher("argon2id", domain.DefaultArgon2idHasher)
+		})
+
+		hasher := domain.DefaultArgon2idHasher
+		hasher.Time++
+		domain.RegisterPasswordHasher("argon2id", hasher)
+
+		steps := &loginRehashStub{loginStub: loginStub{ciphertext: ciphertext}}
+		assert.Nil(auth.Login(ctx, steps, dto))
+		assert.NotEqual(ciphertext, steps.rehashed)
+		assert.False(steps.rehashed.NeedsRehash())
+		assert.True(steps.rehashed.Compare(password))
+	})
+
+	t.Run("password mismatch", func(t *testing.T) {
+		dto := dto
+		dto.Password = "87654321"
+
+		steps := &loginRehashStub{loginStub: loginStub{ciphertext: "$argon2id$m=1,t=1,p=1$salt$hash"}}
+		assert.ErrorIs(t, auth.Login(ctx, steps, dto), errPasswordMismatch)
+		assert.Empty(t, steps.rehashed)
+	})
+
+	t.Run("mismatch allowed", func(t *testing.T) {
+		dto := dto
+		dto.Password = "87654321"
+
+		steps := &loginRehashFlagStub{&loginRehashStub{loginStub: loginStub{ciphertext: "$argon2id$m=1,t=1,p=1$salt$hash"}}}
+		assert.Nil(t, auth.Login(ctx, steps, dto))
+		assert.Empty(t, steps.rehashed)
+	})
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+)
+
+// Second stage of Login for a User with TOTP enrolled. Implement the steps of
+// loginLockout to limit the guesses for the email, since the challenge can be
+// used until it expires. Wrong codes count as failed logins.
+type loginTOTP interface {
+	// 1. Verify the challenge returned by Login. It should have the email as the
+	// subject, and the attempt passed to CreateSecondFactorChallenge.
+	VerifySecondFactorChallenge(ctx context.Context, challenge string) (domain.Email, LoginAttempt, error)
+
+	// 2. Load the enrolled secret, and the last time step that was accepted for
+	// the User.
+	FindTOTPSecretByEmail(ctx context.Context, email domain.Email) (secret domain.TOTPSecret, lastTimeStep int64, err error)
+
+	// 3. What error to return if the code does not match, or is replayed?
+	WhenTOTPMatch(ctx context.Context, match bool) error
+
+	// 4. Record the time step, so that the same code cannot be used again. Only
+	// called when the code matches.
+	SaveLastUsedTimeStep(ctx context.Context, email domain.Email, timeStep int64) error
+}
+
+type LoginTOTPDto struct {
+	Challenge string
+	Code      string
+}
+
+func (d LoginTOTPDto) Validate() error {
+	if d.Challenge == "" {
+		return errors.New("auth: challenge required")
+	}
+
+	return domain.OTP(d.Code).Validate()
+}
+
+func LoginTOTP(ctx context.Context, steps loginTOTP, dto LoginTOTPDto) error {
+	if err := domain.Validate(dto); err != nil {
+		return err
+	}
+
+	email, attempt, err := steps.VerifySecondFactorChallenge(ctx, dto.Challenge)
+	if err != nil {
+		return err
+	}
+
+	lockout, hasLockout := steps.(loginLockout)
+	if hasLockout {
+		if err := checkLockout(ctx, lockout, email, ""); err != nil {
+			return err
+		}
+	}
+
+	secret, lastTimeStep, err := steps.FindTOTPSecretByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	timeStep, ok := secret.Verify(domain.OTP(dto.Code), time.Now())
+	match := ok && timeStep > lastTimeStep
+	if hasLockout {
+		if err := countFailedLogins(ctx, lockout, email, "", match); err != nil {
+			return err
+		}
+	}
+
+	if err := steps.WhenTOTPMatch(ctx, match); err != nil {
+		return err
+	}
+
+	// Only an accepted time step is recorded, so that a mismatch allowed by
+	// WhenTOTPMatch cannot overwrite it and replay old codes.
+	if match {
+		if err := steps.SaveLastUsedTimeStep(ctx, email, timeStep); err != nil {
+			return err
+		}
+	}
+
+	return rememberLogin(ctx, steps, email, attempt)
+}
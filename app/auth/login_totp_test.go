@@ -0,0 +1,181 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/auth"
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var errTOTPMismatch = errors.New("totp mismatch")
+
+type loginTOTPStub struct {
+	secret       domain.TOTPSecret
+	lastTimeStep int64
+	saved        int64
+	attempt      auth.LoginAttempt
+}
+
+func (s *loginTOTPStub) VerifySecondFactorChallenge(ctx context.Context, challenge string) (domain.Email, auth.LoginAttempt, error) {
+	if challenge != "challenge" {
+		return "", auth.LoginAttempt{}, wantErr
+	}
+
+	return "john.doe@mail.com", s.attempt, nil
+}
+
+func (s *loginTOTPStub) FindTOTPSecretByEmail(ctx context.Context, email domain.Email) (domain.TOTPSecret, int64, error) {
+	return s.secret, s.lastTimeStep, nil
+}
+
+func (s *loginTOTPStub) WhenTOTPMatch(ctx context.Context, match bool) error {
+	if !match {
+		return errTOTPMismatch
+	}
+
+	return nil
+}
+
+func (s *loginTOTPStub) SaveLastUsedTimeStep(ctx context.Context, email domain.Email, timeStep int64) error {
+	s.saved = timeStep
+	return nil
+}
+
+// loginTOTPFlagStub allows mismatched codes, e.g. to flag them instead.
+type loginTOTPFlagStub struct {
+	*loginTOTPStub
+}
+
+func (s *loginTOTPFlagStub) WhenTOTPMatch(ctx context.Context, match bool) error {
+	return nil
+}
+
+type loginTOTPLockoutStub struct {
+	*loginTOTPStub
+	*loginLockoutStub
+}
+
+// loginTOTPFlowStub completes both stages of Login with TOTP enrolled.
+type loginTOTPFlowStub struct {
+	*loginLockoutStub
+	*loginTOTPStub
+}
+
+func (s *loginTOTPFlowStub) HasSecondFactor(ctx context.Context, email domain.Email) (bool, error) {
+	return true, nil
+}
+
+func (s *loginTOTPFlowStub) CreateSecondFactorChallenge(ctx context.Context, email domain.Email, attempt auth.LoginAttempt) (string, error) {
+	return "challenge", nil
+}
+
+func TestLoginTOTP(t *testing.T) {
+	secret, err := domain.NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	code, err := secret.Code(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dto := auth.LoginTOTPDto{
+		Challenge: "challenge",
+		Code:      string(code),
+	}
+
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		steps := &loginTOTPStub{secret: secret}
+		assert.Nil(t, auth.LoginTOTP(ctx, steps, dto))
+		assert.Equal(t, domain.TOTPTimeStep(now), steps.saved)
+	})
+
+	t.Run("replayed", func(t *testing.T) {
+		steps := &loginTOTPStub{secret: secret, lastTimeStep: domain.TOTPTimeStep(now)}
+		assert.ErrorIs(t, auth.LoginTOTP(ctx, steps, dto), errTOTPMismatch)
+	})
+
+	t.Run("mismatch allowed", func(t *testing.T) {
+		steps := &loginTOTPFlagStub{&loginTOTPStub{secret: secret, lastTimeStep: domain.TOTPTimeStep(now), saved: -1}}
+		assert.Nil(t, auth.LoginTOTP(ctx, steps, dto))
+
+		// The last time step is not overwritten.
+		assert.Equal(t, int64(-1), steps.saved)
+	})
+
+	t.Run("invalid challenge", func(t *testing.T) {
+		dto := dto
+		dto.Challenge = "invalid"
+
+		steps := &loginTOTPStub{secret: secret}
+		assert.ErrorIs(t, auth.LoginTOTP(ctx, steps, dto), wantErr)
+	})
+
+	t.Run("invalid code", func(t *testing.T) {
+		dto := dto
+		dto.Code = "abc"
+
+		steps := &loginTOTPStub{secret: secret}
+		assert.ErrorIs(t, auth.LoginTOTP(ctx, steps, dto), domain.ErrOTPInvalidFormat)
+	})
+
+	t.Run("locked out", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := &loginTOTPLockoutStub{
+			loginTOTPStub:    &loginTOTPStub{secret: secret},
+			loginLockoutStub: newLoginLockoutStub(),
+		}
+
+		// The code of another time step.
+		other, err := secret.Code(now.Add(time.Hour))
+		assert.Nil(err)
+
+		wrong := dto
+		wrong.Code = string(other)
+
+		for i := 0; i < 3; i++ {
+			assert.ErrorIs(auth.LoginTOTP(ctx, steps, wrong), errTOTPMismatch)
+		}
+		assert.Equal(3, steps.byEmail["john.doe@mail.com"].Count)
+
+		// The correct code is rejected while locked out.
+		assert.ErrorIs(auth.LoginTOTP(ctx, steps, dto), auth.ErrLockedOut)
+		assert.Zero(steps.saved)
+	})
+
+	t.Run("locked out across login", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := &loginTOTPFlowStub{
+			loginLockoutStub: newLoginLockoutStub(),
+			loginTOTPStub:    &loginTOTPStub{secret: secret},
+		}
+
+		login := auth.LoginDto{Email: "john.doe@mail.com", Password: string(password)}
+
+		other, err := secret.Code(now.Add(time.Hour))
+		assert.Nil(err)
+
+		wrong := dto
+		wrong.Code = string(other)
+
+		// Logging in again with the password does not reset the counter of
+		// the wrong codes.
+		for i := 0; i < 3; i++ {
+			assert.ErrorIs(auth.Login(ctx, steps, login), auth.ErrSecondFactorRequired)
+			assert.ErrorIs(auth.LoginTOTP(ctx, steps, wrong), errTOTPMismatch)
+		}
+
+		assert.ErrorIs(auth.Login(ctx, steps, login), auth.ErrLockedOut)
+		assert.ErrorIs(auth.LoginTOTP(ctx, steps, dto), auth.ErrLockedOut)
+	})
+}
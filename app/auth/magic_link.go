@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+)
+
+// Magic link errors.
+var (
+	ErrDeviceIDRequired = errors.New("auth: device id required")
+
+	// ErrDeviceMismatch is returned by ConsumeMagicLink when the link is opened
+	// on a different device or browser than the one that requested it.
+	ErrDeviceMismatch = errors.New("auth: device mismatch")
+)
+
+// Flow for a User to login without password, by opening a link sent to the
+// email.
+type requestMagicLink interface {
+	// 1. Rate limit the requests, e.g. by email and device.
+	Allow(ctx context.Context, dto RequestMagicLinkDto) error
+
+	// 2. Checks if the email exists.
+	CheckEmailExists(ctx context.Context, email domain.Email) (bool, error)
+
+	// 3. What error to return if the email do/do not exists?
+	WhenEmailExists(ctx context.Context, exists bool) error
+
+	// 4. Generate a short-lived, single-use token with the email as the
+	// subject, bound to the device, e.g. with tokens.Tokens.IssueStamped. The
+	// binding is a hash, so that the device id is not leaked in the link.
+	GenerateMagicLinkToken(ctx context.Context, email domain.Email, binding string) (string, error)
+
+	// 5. Send the email containing the magic link and token to the email.
+	SendMagicLinkEmail(ctx context.Context, email domain.Email, token string) error
+}
+
+type RequestMagicLinkDto struct {
+	Email    string
+	DeviceID string `desc:"Identifier of the requesting device or browser, e.g. from a cookie"`
+}
+
+func (d RequestMagicLinkDto) Validate() error {
+	if d.DeviceID == "" {
+		return ErrDeviceIDRequired
+	}
+
+	return domain.Email(d.Email).Validate()
+}
+
+func RequestMagicLink(ctx context.Context, steps requestMagicLink, dto RequestMagicLinkDto) error {
+	if err := domain.Validate(dto); err != nil {
+		return err
+	}
+
+	if err := steps.Allow(ctx, dto); err != nil {
+		return err
+	}
+
+	email := domain.Email(dto.Email)
+	exists, err := steps.CheckEmailExists(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	if err := steps.WhenEmailExists(ctx, exists); err != nil {
+		return err
+	}
+
+	token, err := steps.GenerateMagicLinkToken(ctx, email, deviceBinding(dto.DeviceID))
+	if err != nil {
+		return err
+	}
+
+	return steps.SendMagicLinkEmail(ctx, email, token)
+}
+
+// A continuation of RequestMagicLink, when the User opens the link. The link
+// only proves ownership of the email, so the optional steps of
+// loginSecondFactor and loginDevice are applied as in Login.
+type consumeMagicLink interface {
+	// 1. Verify the token, without using it, e.g. with tokens.Tokens.Verify.
+	// Returns the email and the device binding of the token.
+	VerifyMagicLinkToken(ctx context.Context, token string) (email domain.Email, binding string, err error)
+
+	// 2. Mark the token as used, e.g. with tokens.Tokens.Use, so that it
+	// cannot be reused. Only called after the device binding matches, so that
+	// opening the link in another browser does not burn the token.
+	ConsumeMagicLinkToken(ctx context.Context, token string) error
+
+	// 3. Load the identity of the User.
+	FindIdentityByEmail(ctx context.Context, email domain.Email) (Identity, error)
+}
+
+type ConsumeMagicLinkDto struct {
+	Token     string
+	DeviceID  string `desc:"Identifier of the device or browser opening the link"`
+	UserAgent string `desc:"Optional client user agent"`
+}
+
+func (d ConsumeMagicLinkDto) Validate() error {
+	if d.Token == "" {
+		return errors.New("auth: token required")
+	}
+
+	if d.DeviceID == "" {
+		return ErrDeviceIDRequired
+	}
+
+	return nil
+}
+
+func ConsumeMagicLink(ctx context.Context, steps consumeMagicLink, dto ConsumeMagicLinkDto) (Identity, error) {
+	if err := domain.Validate(dto); err != nil {
+		return Identity{}, err
+	}
+
+	email, binding, err := steps.VerifyMagicLinkToken(ctx, dto.Token)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(binding), []byte(deviceBinding(dto.DeviceID))) != 1 {
+		return Identity{}, ErrDeviceMismatch
+	}
+
+	if err := steps.ConsumeMagicLinkToken(ctx, dto.Token); err != nil {
+		return Identity{}, err
+	}
+
+	attempt, err := newLoginAttempt(ctx, steps, email, LoginDto{
+		DeviceID:  dto.DeviceID,
+		UserAgent: dto.UserAgent,
+	})
+	if err != nil {
+		return Identity{}, err
+	}
+
+	if err := requireSecondFactor(ctx, steps, email, attempt); err != nil {
+		return Identity{}, err
+	}
+
+	id, err := steps.FindIdentityByEmail(ctx, email)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	if err := rememberLogin(ctx, steps, email, attempt); err != nil {
+		return Identity{}, err
+	}
+
+	return id, nil
+}
+
+func deviceBinding(deviceID string) string {
+	sum := sha256.Sum256([]byte(deviceID))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
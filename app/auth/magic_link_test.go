@@ -0,0 +1,191 @@
+package auth_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/auth"
+	"github.com/alextanhongpin/go-service-oriented-package/app/tokens"
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var errTooManyRequests = errors.New("too many requests")
+
+const magicLinkPurpose = "magic_link"
+
+type magicLinkStub struct {
+	tokens   *tokens.Tokens
+	requests int
+	sent     string
+}
+
+func newMagicLinkStub(t *testing.T) *magicLinkStub {
+	t.Helper()
+
+	tk, err := tokens.New(tokens.Key{ID: "1", Secret: bytes.Repeat([]byte("a"), 32)})
+	assert.Nil(t, err)
+	tk.Nonces = tokens.NewMemoryNonceStore()
+
+	return &magicLinkStub{tokens: tk}
+}
+
+func (s *magicLinkStub) Allow(ctx context.Context, dto auth.RequestMagicLinkDto) error {
+	s.requests++
+	if s.requests > 2 {
+		return errTooManyRequests
+	}
+
+	return nil
+}
+
+func (s *magicLinkStub) CheckEmailExists(ctx context.Context, email domain.Email) (bool, error) {
+	return email == "john.doe@mail.com", nil
+}
+
+func (s *magicLinkStub) WhenEmailExists(ctx context.Context, exists bool) error {
+	if !exists {
+		return errEmailNotFound
+	}
+
+	return nil
+}
+
+func (s *magicLinkStub) GenerateMagicLinkToken(ctx context.Context, email domain.Email, binding string) (string, error) {
+	return s.tokens.IssueStamped(string(email), magicLinkPurpose, binding, 15*time.Minute)
+}
+
+func (s *magicLinkStub) SendMagicLinkEmail(ctx context.Context, email domain.Email, token string) error {
+	s.sent = token
+	return nil
+}
+
+func (s *magicLinkStub) VerifyMagicLinkToken(ctx context.Context, token string) (domain.Email, string, error) {
+	c, err := s.tokens.Verify(token, magicLinkPurpose)
+	if err != nil {
+		return "", "", err
+	}
+
+	return domain.Email(c.Subject), c.Stamp, nil
+}
+
+func (s *magicLinkStub) ConsumeMagicLinkToken(ctx context.Context, token string) error {
+	_, err := s.tokens.Use(ctx, token, magicLinkPurpose)
+	return err
+}
+
+func (s *magicLinkStub) FindIdentityByEmail(ctx context.Context, email domain.Email) (auth.Identity, error) {
+	return auth.Identity{UserID: "1", Email: email}, nil
+}
+
+type magicLinkSecondFactorStub struct {
+	*magicLinkStub
+	enrolled bool
+	attempt  auth.LoginAttempt
+}
+
+func (s *magicLinkSecondFactorStub) HasSecondFactor(ctx context.Context, email domain.Email) (bool, error) {
+	return s.enrolled, nil
+}
+
+func (s *magicLinkSecondFactorStub) CreateSecondFactorChallenge(ctx context.Context, email domain.Email, attempt auth.LoginAttempt) (string, error) {
+	s.attempt = attempt
+	return "challenge", nil
+}
+
+func TestMagicLink(t *testing.T) {
+	ctx := context.Background()
+	dto := auth.RequestMagicLinkDto{
+		Email:    "john.doe@mail.com",
+		DeviceID: "device-1",
+	}
+
+	t.Run("success", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newMagicLinkStub(t)
+		assert.Nil(auth.RequestMagicLink(ctx, steps, dto))
+		assert.NotContains(steps.sent, dto.DeviceID)
+
+		consume := auth.ConsumeMagicLinkDto{Token: steps.sent, DeviceID: "device-1"}
+		id, err := auth.ConsumeMagicLink(ctx, steps, consume)
+		assert.Nil(err)
+		assert.Equal(auth.Identity{UserID: "1", Email: "john.doe@mail.com"}, id)
+
+		// The link is single-use.
+		_, err = auth.ConsumeMagicLink(ctx, steps, consume)
+		assert.ErrorIs(err, tokens.ErrTokenUsed)
+	})
+
+	t.Run("second factor", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := &magicLinkSecondFactorStub{magicLinkStub: newMagicLinkStub(t), enrolled: true}
+		assert.Nil(auth.RequestMagicLink(ctx, steps, dto))
+
+		consume := auth.ConsumeMagicLinkDto{Token: steps.sent, DeviceID: "device-1"}
+		_, err := auth.ConsumeMagicLink(ctx, steps, consume)
+
+		var sfErr *auth.SecondFactorRequiredError
+		assert.True(errors.As(err, &sfErr))
+		assert.Equal("challenge", sfErr.Challenge)
+		assert.Equal(auth.ReasonSecondFactor, sfErr.Reason)
+		assert.Equal(domain.NewDeviceFingerprint("device-1", ""), steps.attempt.Fingerprint)
+	})
+
+	t.Run("second factor not enrolled", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := &magicLinkSecondFactorStub{magicLinkStub: newMagicLinkStub(t)}
+		assert.Nil(auth.RequestMagicLink(ctx, steps, dto))
+
+		consume := auth.ConsumeMagicLinkDto{Token: steps.sent, DeviceID: "device-1"}
+		id, err := auth.ConsumeMagicLink(ctx, steps, consume)
+		assert.Nil(err)
+		assert.Equal("1", id.UserID)
+	})
+
+	t.Run("device mismatch", func(t *testing.T) {
+		steps := newMagicLinkStub(t)
+		assert.Nil(t, auth.RequestMagicLink(ctx, steps, dto))
+
+		consume := auth.ConsumeMagicLinkDto{Token: steps.sent, DeviceID: "device-2"}
+		_, err := auth.ConsumeMagicLink(ctx, steps, consume)
+		assert.ErrorIs(t, err, auth.ErrDeviceMismatch)
+
+		// The token is not used, and still opens on the requesting device.
+		consume.DeviceID = "device-1"
+		_, err = auth.ConsumeMagicLink(ctx, steps, consume)
+		assert.Nil(t, err)
+	})
+
+	t.Run("email not found", func(t *testing.T) {
+		dto := dto
+		dto.Email = "jane.doe@mail.com"
+
+		steps := newMagicLinkStub(t)
+		assert.ErrorIs(t, auth.RequestMagicLink(ctx, steps, dto), errEmailNotFound)
+		assert.Empty(t, steps.sent)
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		steps := newMagicLinkStub(t)
+		assert.Nil(t, auth.RequestMagicLink(ctx, steps, dto))
+		assert.Nil(t, auth.RequestMagicLink(ctx, steps, dto))
+		assert.ErrorIs(t, auth.RequestMagicLink(ctx, steps, dto), errTooManyRequests)
+	})
+
+	t.Run("device id required", func(t *testing.T) {
+		dto := dto
+		dto.DeviceID = ""
+
+		steps := newMagicLinkStub(t)
+		assert.ErrorIs(t, auth.RequestMagicLink(ctx, steps, dto), auth.ErrDeviceIDRequired)
+
+		_, err := auth.ConsumeMagicLink(ctx, steps, auth.ConsumeMagicLinkDto{Token: "token"})
+		assert.ErrorIs(t, err, auth.ErrDeviceIDRequired)
+	})
+}
@@ -0,0 +1,294 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/webauthn"
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+)
+
+// Passkey errors.
+var (
+	ErrPasskeyNotFound = errors.New("auth: passkey not found")
+	ErrPasskeyExists   = errors.New("auth: passkey already registered")
+)
+
+// Passkey is a WebAuthn credential registered by a User.
+type Passkey struct {
+	UserID     string
+	Name       string `desc:"Label chosen by the User, e.g. the device name"`
+	Credential webauthn.Credential
+}
+
+// Flow for a logged-in User to register a passkey.
+type beginPasskeyRegistration interface {
+	// 1. The relying party that verifies the passkeys.
+	RelyingParty() *webauthn.RelyingParty
+
+	// 2. Load the passkeys of the User, so that the authenticator does not
+	// register another one.
+	FindPasskeysByUserID(ctx context.Context, userID string) ([]Passkey, error)
+
+	// 3. Keep the challenge for the device until the ceremony is finished, e.g.
+	// in a cache that expires after webauthn.RelyingParty.Timeout.
+	SavePasskeyChallenge(ctx context.Context, deviceID string, challenge []byte) error
+}
+
+type BeginPasskeyRegistrationDto struct {
+	UserID   string
+	Email    string `desc:"Shown by the authenticator to pick the passkey"`
+	DeviceID string `desc:"Identifier of the device or browser, e.g. from a cookie"`
+}
+
+func (d BeginPasskeyRegistrationDto) Validate() error {
+	if d.UserID == "" {
+		return errors.New("auth: user id required")
+	}
+
+	if d.DeviceID == "" {
+		return ErrDeviceIDRequired
+	}
+
+	return nil
+}
+
+// BeginPasskeyRegistration returns the options for
+// navigator.credentials.create.
+func BeginPasskeyRegistration(ctx context.Context, steps beginPasskeyRegistration, dto BeginPasskeyRegistrationDto) (*webauthn.CreationOptions, error) {
+	if err := domain.Validate(dto); err != nil {
+		return nil, err
+	}
+
+	passkeys, err := steps.FindPasskeysByUserID(ctx, dto.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := steps.RelyingParty().CreationOptions(webauthn.User{
+		ID:   []byte(dto.UserID),
+		Name: dto.Email,
+	}, credentials(passkeys))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := steps.SavePasskeyChallenge(ctx, dto.DeviceID, opts.Challenge); err != nil {
+		return nil, err
+	}
+
+	return opts, nil
+}
+
+// A continuation of BeginPasskeyRegistration.
+type finishPasskeyRegistration interface {
+	// 1. The relying party that verifies the passkeys.
+	RelyingParty() *webauthn.RelyingParty
+
+	// 2. Load and delete the challenge of the device, so that it can only be
+	// used once.
+	ConsumePasskeyChallenge(ctx context.Context, deviceID string) ([]byte, error)
+
+	// 3. Load the passkey by the credential ID. Return ErrPasskeyNotFound when
+	// it is not registered.
+	FindPasskey(ctx context.Context, credentialID []byte) (Passkey, error)
+
+	// 4. Save the passkey.
+	SavePasskey(ctx context.Context, passkey Passkey) error
+}
+
+type FinishPasskeyRegistrationDto struct {
+	UserID     string
+	DeviceID   string
+	Name       string `desc:"Optional label, e.g. the device name"`
+	Credential webauthn.CredentialCreation
+}
+
+func (d FinishPasskeyRegistrationDto) Validate() error {
+	if d.UserID == "" {
+		return errors.New("auth: user id required")
+	}
+
+	if d.DeviceID == "" {
+		return ErrDeviceIDRequired
+	}
+
+	return nil
+}
+
+func FinishPasskeyRegistration(ctx context.Context, steps finishPasskeyRegistration, dto FinishPasskeyRegistrationDto) error {
+	if err := domain.Validate(dto); err != nil {
+		return err
+	}
+
+	challenge, err := steps.ConsumePasskeyChallenge(ctx, dto.DeviceID)
+	if err != nil {
+		return err
+	}
+
+	cred, err := steps.RelyingParty().VerifyCreation(dto.Credential, challenge)
+	if err != nil {
+		return err
+	}
+
+	_, err = steps.FindPasskey(ctx, cred.ID)
+	if err == nil {
+		return ErrPasskeyExists
+	}
+
+	if !errors.Is(err, ErrPasskeyNotFound) {
+		return err
+	}
+
+	return steps.SavePasskey(ctx, Passkey{
+		UserID:     dto.UserID,
+		Name:       dto.Name,
+		Credential: *cred,
+	})
+}
+
+// Flow for a User to login with a passkey. The User picks the passkey on the
+// authenticator, so no email is needed.
+type beginPasskeyLogin interface {
+	// 1. The relying party that verifies the passkeys.
+	RelyingParty() *webauthn.RelyingParty
+
+	// 2. Keep the challenge for the device until the ceremony is finished, e.g.
+	// in a cache that expires after webauthn.RelyingParty.Timeout.
+	SavePasskeyChallenge(ctx context.Context, deviceID string, challenge []byte) error
+}
+
+type BeginPasskeyLoginDto struct {
+	DeviceID string `desc:"Identifier of the device or browser, e.g. from a cookie"`
+}
+
+func (d BeginPasskeyLoginDto) Validate() error {
+	if d.DeviceID == "" {
+		return ErrDeviceIDRequired
+	}
+
+	return nil
+}
+
+// BeginPasskeyLogin returns the options for navigator.credentials.get.
+func BeginPasskeyLogin(ctx context.Context, steps beginPasskeyLogin, dto BeginPasskeyLoginDto) (*webauthn.RequestOptions, error) {
+	if err := domain.Validate(dto); err != nil {
+		return nil, err
+	}
+
+	opts, err := steps.RelyingParty().RequestOptions(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := steps.SavePasskeyChallenge(ctx, dto.DeviceID, opts.Challenge); err != nil {
+		return nil, err
+	}
+
+	return opts, nil
+}
+
+// A continuation of BeginPasskeyLogin. A passkey asserted without user
+// verification is only a possession factor, so the optional steps of
+// loginSecondFactor and loginDevice are applied as in Login.
+type finishPasskeyLogin interface {
+	// 1. The relying party that verifies the passkeys.
+	RelyingParty() *webauthn.RelyingParty
+
+	// 2. Load and delete the challenge of the device, so that it can only be
+	// used once.
+	ConsumePasskeyChallenge(ctx context.Context, deviceID string) ([]byte, error)
+
+	// 3. Load the passkey by the credential ID. Return ErrPasskeyNotFound when
+	// it is not registered.
+	FindPasskey(ctx context.Context, credentialID []byte) (Passkey, error)
+
+	// 4. What error to return when the sign count did not increase, which
+	// means the authenticator may be cloned? Return an error to refuse, e.g.
+	// webauthn.ErrSignCountInvalid after disabling the passkey, or nil to
+	// allow the login.
+	WhenPasskeyCloned(ctx context.Context, passkey Passkey) error
+
+	// 5. Save the sign count and backup state of the passkey.
+	UpdatePasskey(ctx context.Context, passkey Passkey) error
+
+	// 6. Load the User of the passkey.
+	FindIdentityByUserID(ctx context.Context, userID string) (Identity, error)
+}
+
+type FinishPasskeyLoginDto struct {
+	DeviceID   string
+	UserAgent  string `desc:"Optional client user agent"`
+	Credential webauthn.CredentialAssertion
+}
+
+func (d FinishPasskeyLoginDto) Validate() error {
+	if d.DeviceID == "" {
+		return ErrDeviceIDRequired
+	}
+
+	return nil
+}
+
+func FinishPasskeyLogin(ctx context.Context, steps finishPasskeyLogin, dto FinishPasskeyLoginDto) (Identity, error) {
+	if err := domain.Validate(dto); err != nil {
+		return Identity{}, err
+	}
+
+	challenge, err := steps.ConsumePasskeyChallenge(ctx, dto.DeviceID)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	passkey, err := steps.FindPasskey(ctx, dto.Credential.RawID)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	// The user handle is the user id set in BeginPasskeyRegistration.
+	if string(dto.Credential.Response.UserHandle) != passkey.UserID {
+		return Identity{}, webauthn.ErrCredentialMismatch
+	}
+
+	cred, err := steps.RelyingParty().VerifyAssertion(dto.Credential, challenge, passkey.Credential)
+	switch {
+	case errors.Is(err, webauthn.ErrSignCountInvalid):
+		// The signature is valid, but the sign count is not updated.
+		if err := steps.WhenPasskeyCloned(ctx, passkey); err != nil {
+			return Identity{}, err
+		}
+	case err != nil:
+		return Identity{}, err
+	default:
+		passkey.Credential = *cred
+		if err := steps.UpdatePasskey(ctx, passkey); err != nil {
+			return Identity{}, err
+		}
+	}
+
+	id, err := steps.FindIdentityByUserID(ctx, passkey.UserID)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	if !dto.Credential.UserVerified() {
+		err := loginIdentity(ctx, steps, id, LoginDto{
+			DeviceID:  dto.DeviceID,
+			UserAgent: dto.UserAgent,
+		})
+		if err != nil {
+			return Identity{}, err
+		}
+	}
+
+	return id, nil
+}
+
+func credentials(passkeys []Passkey) []webauthn.Credential {
+	creds := make([]webauthn.Credential, len(passkeys))
+	for i, p := range passkeys {
+		creds[i] = p.Credential
+	}
+
+	return creds
+}
@@ -0,0 +1,350 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/auth"
+	"github.com/alextanhongpin/go-service-oriented-package/app/webauthn"
+	"github.com/alextanhongpin/go-service-oriented-package/app/webauthn/webauthntest"
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	errChallengeNotFound = errors.New("challenge not found")
+	errPasskeyCloned     = errors.New("passkey cloned")
+)
+
+const passkeyOrigin = "https://example.com"
+
+type passkeyStub struct {
+	rp         *webauthn.RelyingParty
+	challenges map[string][]byte
+	passkeys   map[string]auth.Passkey
+	cloned     bool
+	allowClone bool
+	enrolled   bool
+}
+
+func newPasskeyStub(t *testing.T) *passkeyStub {
+	t.Helper()
+
+	rp, err := webauthn.New("example.com", "Example", passkeyOrigin)
+	assert.Nil(t, err)
+
+	return &passkeyStub{
+		rp:         rp,
+		challenges: make(map[string][]byte),
+		passkeys:   make(map[string]auth.Passkey),
+	}
+}
+
+func (s *passkeyStub) RelyingParty() *webauthn.RelyingParty {
+	return s.rp
+}
+
+func (s *passkeyStub) FindPasskeysByUserID(ctx context.Context, userID string) ([]auth.Passkey, error) {
+	var res []auth.Passkey
+	for _, p := range s.passkeys {
+		if p.UserID == userID {
+			res = append(res, p)
+		}
+	}
+
+	return res, nil
+}
+
+func (s *passkeyStub) SavePasskeyChallenge(ctx context.Context, deviceID string, challenge []byte) error {
+	s.challenges[deviceID] = challenge
+	return nil
+}
+
+func (s *passkeyStub) ConsumePasskeyChallenge(ctx context.Context, deviceID string) ([]byte, error) {
+	c, ok := s.challenges[deviceID]
+	if !ok {
+		return nil, errChallengeNotFound
+	}
+
+	delete(s.challenges, deviceID)
+	return c, nil
+}
+
+func (s *passkeyStub) FindPasskey(ctx context.Context, credentialID []byte) (auth.Passkey, error) {
+	p, ok := s.passkeys[string(credentialID)]
+	if !ok {
+		return auth.Passkey{}, auth.ErrPasskeyNotFound
+	}
+
+	return p, nil
+}
+
+func (s *passkeyStub) SavePasskey(ctx context.Context, passkey auth.Passkey) error {
+	s.passkeys[string(passkey.Credential.ID)] = passkey
+	return nil
+}
+
+func (s *passkeyStub) WhenPasskeyCloned(ctx context.Context, passkey auth.Passkey) error {
+	s.cloned = true
+	if s.allowClone {
+		return nil
+	}
+
+	return errPasskeyCloned
+}
+
+func (s *passkeyStub) UpdatePasskey(ctx context.Context, passkey auth.Passkey) error {
+	return s.SavePasskey(ctx, passkey)
+}
+
+func (s *passkeyStub) FindIdentityByUserID(ctx context.Context, userID string) (auth.Identity, error) {
+	return auth.Identity{UserID: userID, Email: "john.doe@mail.com"}, nil
+}
+
+func (s *passkeyStub) HasSecondFactor(ctx context.Context, email domain.Email) (bool, error) {
+	return s.enrolled, nil
+}
+
+func (s *passkeyStub) CreateSecondFactorChallenge(ctx context.Context, email domain.Email, attempt auth.LoginAttempt) (string, error) {
+	return "challenge", nil
+}
+
+func registerPasskey(t *testing.T, steps *passkeyStub, a *webauthntest.Authenticator) error {
+	t.Helper()
+
+	ctx := context.Background()
+	opts, err := auth.BeginPasskeyRegistration(ctx, steps, auth.BeginPasskeyRegistrationDto{
+		UserID:   "1",
+		Email:    "john.doe@mail.com",
+		DeviceID: "device",
+	})
+	assert.Nil(t, err)
+
+	res, err := a.Create(*opts)
+	assert.Nil(t, err)
+
+	return auth.FinishPasskeyRegistration(ctx, steps, auth.FinishPasskeyRegistrationDto{
+		UserID:     "1",
+		DeviceID:   "device",
+		Name:       "Laptop",
+		Credential: res,
+	})
+}
+
+func loginPasskey(t *testing.T, steps *passkeyStub, a *webauthntest.Authenticator) (auth.Identity, error) {
+	t.Helper()
+
+	ctx := context.Background()
+	opts, err := auth.BeginPasskeyLogin(ctx, steps, auth.BeginPasskeyLoginDto{DeviceID: "device"})
+	assert.Nil(t, err)
+
+	res, err := a.Get(*opts)
+	assert.Nil(t, err)
+
+	return auth.FinishPasskeyLogin(ctx, steps, auth.FinishPasskeyLoginDto{
+		DeviceID:   "device",
+		Credential: res,
+	})
+}
+
+func newPasskeyAuthenticator(t *testing.T) *webauthntest.Authenticator {
+	t.Helper()
+
+	a, err := webauthntest.New(webauthn.AlgES256, passkeyOrigin)
+	assert.Nil(t, err)
+
+	return a
+}
+
+func TestPasskeyRegistration(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newPasskeyStub(t)
+		a := newPasskeyAuthenticator(t)
+		assert.Nil(registerPasskey(t, steps, a))
+		assert.Empty(steps.challenges)
+
+		p, err := steps.FindPasskey(ctx, a.CredentialID())
+		assert.Nil(err)
+		assert.Equal("1", p.UserID)
+		assert.Equal("Laptop", p.Name)
+	})
+
+	t.Run("exclude registered", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newPasskeyStub(t)
+		a := newPasskeyAuthenticator(t)
+		assert.Nil(registerPasskey(t, steps, a))
+
+		opts, err := auth.BeginPasskeyRegistration(ctx, steps, auth.BeginPasskeyRegistrationDto{
+			UserID:   "1",
+			Email:    "john.doe@mail.com",
+			DeviceID: "device",
+		})
+		assert.Nil(err)
+		assert.Len(opts.ExcludeCredentials, 1)
+		assert.Equal(webauthn.Bytes(a.CredentialID()), opts.ExcludeCredentials[0].ID)
+
+		// The authenticator ignores the exclude list.
+		res, err := a.Create(*opts)
+		assert.Nil(err)
+
+		err = auth.FinishPasskeyRegistration(ctx, steps, auth.FinishPasskeyRegistrationDto{
+			UserID:     "2",
+			DeviceID:   "device",
+			Credential: res,
+		})
+		assert.ErrorIs(err, auth.ErrPasskeyExists)
+		assert.Equal("1", steps.passkeys[string(a.CredentialID())].UserID)
+	})
+
+	t.Run("challenge used once", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newPasskeyStub(t)
+		opts, err := auth.BeginPasskeyRegistration(ctx, steps, auth.BeginPasskeyRegistrationDto{
+			UserID:   "1",
+			Email:    "john.doe@mail.com",
+			DeviceID: "device",
+		})
+		assert.Nil(err)
+
+		res, err := newPasskeyAuthenticator(t).Create(*opts)
+		assert.Nil(err)
+
+		dto := auth.FinishPasskeyRegistrationDto{UserID: "1", DeviceID: "device", Credential: res}
+		assert.Nil(auth.FinishPasskeyRegistration(ctx, steps, dto))
+		assert.ErrorIs(auth.FinishPasskeyRegistration(ctx, steps, dto), errChallengeNotFound)
+	})
+
+	t.Run("device id required", func(t *testing.T) {
+		_, err := auth.BeginPasskeyRegistration(ctx, newPasskeyStub(t), auth.BeginPasskeyRegistrationDto{UserID: "1"})
+		assert.ErrorIs(t, err, auth.ErrDeviceIDRequired)
+	})
+}
+
+func TestPasskeyLogin(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newPasskeyStub(t)
+		a := newPasskeyAuthenticator(t)
+		assert.Nil(registerPasskey(t, steps, a))
+
+		for i := 1; i <= 2; i++ {
+			id, err := loginPasskey(t, steps, a)
+			assert.Nil(err)
+			assert.Equal("1", id.UserID)
+			assert.Equal(uint32(i), steps.passkeys[string(a.CredentialID())].Credential.SignCount)
+		}
+	})
+
+	t.Run("second factor without user verification", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newPasskeyStub(t)
+		steps.enrolled = true
+		a := newPasskeyAuthenticator(t)
+		assert.Nil(registerPasskey(t, steps, a))
+
+		// User verification counts as the second factor.
+		_, err := loginPasskey(t, steps, a)
+		assert.Nil(err)
+
+		a.UserVerified = false
+		_, err = loginPasskey(t, steps, a)
+
+		var sfErr *auth.SecondFactorRequiredError
+		assert.True(errors.As(err, &sfErr))
+		assert.Equal("challenge", sfErr.Challenge)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		steps := newPasskeyStub(t)
+		a := newPasskeyAuthenticator(t)
+		assert.Nil(t, registerPasskey(t, steps, a))
+
+		_, err := loginPasskey(t, steps, newPasskeyAuthenticator(t))
+		assert.ErrorIs(t, err, auth.ErrPasskeyNotFound)
+	})
+
+	t.Run("user handle mismatch", func(t *testing.T) {
+		steps := newPasskeyStub(t)
+		a := newPasskeyAuthenticator(t)
+		assert.Nil(t, registerPasskey(t, steps, a))
+
+		p := steps.passkeys[string(a.CredentialID())]
+		p.UserID = "2"
+		steps.passkeys[string(a.CredentialID())] = p
+
+		_, err := loginPasskey(t, steps, a)
+		assert.ErrorIs(t, err, webauthn.ErrCredentialMismatch)
+	})
+
+	t.Run("challenge mismatch", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newPasskeyStub(t)
+		a := newPasskeyAuthenticator(t)
+		assert.Nil(registerPasskey(t, steps, a))
+
+		opts, err := auth.BeginPasskeyLogin(ctx, steps, auth.BeginPasskeyLoginDto{DeviceID: "device"})
+		assert.Nil(err)
+
+		res, err := a.Get(*opts)
+		assert.Nil(err)
+
+		// The response is replayed on another device.
+		_, err = auth.BeginPasskeyLogin(ctx, steps, auth.BeginPasskeyLoginDto{DeviceID: "other"})
+		assert.Nil(err)
+
+		_, err = auth.FinishPasskeyLogin(ctx, steps, auth.FinishPasskeyLoginDto{DeviceID: "other", Credential: res})
+		assert.ErrorIs(err, webauthn.ErrChallengeMismatch)
+	})
+
+	t.Run("cloned", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newPasskeyStub(t)
+		a := newPasskeyAuthenticator(t)
+		assert.Nil(registerPasskey(t, steps, a))
+		clone := *a
+
+		_, err := loginPasskey(t, steps, a)
+		assert.Nil(err)
+		assert.False(steps.cloned)
+
+		_, err = loginPasskey(t, steps, &clone)
+		assert.ErrorIs(err, errPasskeyCloned)
+		assert.True(steps.cloned)
+	})
+
+	t.Run("allow cloned", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newPasskeyStub(t)
+		steps.allowClone = true
+
+		a := newPasskeyAuthenticator(t)
+		assert.Nil(registerPasskey(t, steps, a))
+		clone := *a
+
+		_, err := loginPasskey(t, steps, a)
+		assert.Nil(err)
+
+		id, err := loginPasskey(t, steps, &clone)
+		assert.Nil(err)
+		assert.Equal("1", id.UserID)
+		assert.True(steps.cloned)
+
+		// The sign count is kept.
+		assert.Equal(uint32(1), steps.passkeys[string(a.CredentialID())].Credential.SignCount)
+	})
+}
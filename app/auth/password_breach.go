@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+)
+
+// Optional steps for flows that set a new password, to reject passwords known
+// from breaches, e.g. with a domain.BreachChecker.
+type passwordBreach interface {
+	IsPasswordBreached(ctx context.Context, password domain.Plaintext) (bool, error)
+	WhenPasswordBreached(ctx context.Context, breached bool) error
+}
+
+func checkPasswordBreached(ctx context.Context, steps any, password domain.Plaintext) error {
+	s, ok := steps.(passwordBreach)
+	if !ok {
+		return nil
+	}
+
+	breached, err := s.IsPasswordBreached(ctx, password)
+	if err != nil {
+		return err
+	}
+
+	return s.WhenPasswordBreached(ctx, breached)
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+)
+
+// Optional steps for flows that set a new password, so that the User cannot
+// cycle back to any of their recent passwords.
+type passwordHistory interface {
+	// FindPasswordHistory returns the last N ciphertexts of the User, including
+	// the current one. The steps decide N.
+	FindPasswordHistory(ctx context.Context, email domain.Email) ([]domain.Ciphertext, error)
+
+	// SavePasswordHistory records the new ciphertext, and may drop the ones
+	// older than the last N.
+	SavePasswordHistory(ctx context.Context, email domain.Email, ciphertext domain.Ciphertext) error
+}
+
+// Optional steps for flows without WhenPasswordIsReused, e.g. ResetPassword.
+type passwordReuse interface {
+	passwordHistory
+	WhenPasswordIsReused(ctx context.Context, isPasswordReused bool) error
+}
+
+// isPasswordInHistory compares the password against each ciphertext in the
+// history.
+func isPasswordInHistory(ctx context.Context, steps any, email domain.Email, password domain.Plaintext) (bool, error) {
+	s, ok := steps.(passwordHistory)
+	if !ok {
+		return false, nil
+	}
+
+	history, err := s.FindPasswordHistory(ctx, email)
+	if err != nil {
+		return false, err
+	}
+
+	for _, ciphertext := range history {
+		if ciphertext.Compare(password) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func savePasswordHistory(ctx context.Context, steps any, email domain.Email, ciphertext domain.Ciphertext) error {
+	s, ok := steps.(passwordHistory)
+	if !ok {
+		return nil
+	}
+
+	return s.SavePasswordHistory(ctx, email, ciphertext)
+}
@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+)
+
+// Optional step for flows that set a new password, to enforce another policy
+// than domain.DefaultPasswordPolicy.
+type passwordPolicy interface {
+	PasswordPolicy() domain.PasswordPolicy
+}
+
+// checkPasswordPolicy checks the new password against the policy of the steps,
+// or the domain.DefaultPasswordPolicy. Existing passwords are only validated by
+// length with domain.Plaintext.Validate.
+func checkPasswordPolicy(steps any, password domain.Plaintext, email domain.Email, name string) error {
+	policy := domain.DefaultPasswordPolicy
+	if s, ok := steps.(passwordPolicy); ok {
+		policy = s.PasswordPolicy()
+	}
+
+	return policy.Check(password, email, name)
+}
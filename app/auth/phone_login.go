@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/otp"
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+)
+
+// LoginTopic is the topic of the OTP sent for phone number login.
+const LoginTopic = "login"
+
+// Flow for a User to login with only the phone number, by sending an OTP with
+// otp.SendOtp.
+type requestPhoneNumberLogin interface {
+	// 1. Load the User. Return ErrUserNotFound when the User does not exist.
+	FindUserByPhoneNumber(ctx context.Context, phoneNumber domain.PhoneNumber) (Identity, error)
+
+	// 2. The auto-registration policy. Return an error to refuse unknown phone
+	// numbers, or nil to create the User in LoginPhoneNumber.
+	WhenUserNotFound(ctx context.Context, phoneNumber domain.PhoneNumber) error
+
+	// 3. The steps of otp.SendOtp, with LoginTopic as the topic.
+	Allow(ctx context.Context, dto otp.SendOtpDto) error
+	GenerateOtp(ctx context.Context) (domain.OTP, error)
+	CreateSession(ctx context.Context, dto otp.SendOtpDto, otp domain.OTP) error
+	SendMessage(ctx context.Context, dto otp.SendOtpDto, otp domain.OTP) error
+}
+
+type RequestPhoneNumberLoginDto struct {
+	PhoneNumber   string `example:"+601243567890" desc:"Phone number in E164 format"`
+	IdempotentKey string `desc:"unique key to ensure the request is unique, e.g. using the md5 hash of the request"`
+}
+
+func (d RequestPhoneNumberLoginDto) Validate() error {
+	return d.sendOtpDto().Validate()
+}
+
+func (d RequestPhoneNumberLoginDto) sendOtpDto() otp.SendOtpDto {
+	return otp.SendOtpDto{
+		PhoneNumber:   d.PhoneNumber,
+		IdempotentKey: d.IdempotentKey,
+		Topic:         LoginTopic,
+	}
+}
+
+func RequestPhoneNumberLogin(ctx context.Context, steps requestPhoneNumberLogin, dto RequestPhoneNumberLoginDto) error {
+	if err := domain.Validate(dto); err != nil {
+		return err
+	}
+
+	phoneNumber := domain.PhoneNumber(dto.PhoneNumber)
+	_, err := steps.FindUserByPhoneNumber(ctx, phoneNumber)
+	if errors.Is(err, ErrUserNotFound) {
+		if err := steps.WhenUserNotFound(ctx, phoneNumber); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	return otp.SendOtp(ctx, steps, dto.sendOtpDto())
+}
+
+// A continuation of RequestPhoneNumberLogin, to verify the OTP with
+// otp.VerifyOtp. The OTP only proves ownership of the phone number, so the
+// optional steps of loginSecondFactor and loginDevice are applied as in Login.
+type loginPhoneNumber interface {
+	// 1. The steps of otp.VerifyOtp, with LoginTopic as the topic.
+	Verify(ctx context.Context, dto otp.VerifyOtpDto) (idempotentKey string, err error)
+	ClearSession(ctx context.Context, dto otp.VerifyOtpDto) error
+
+	// 2. Load the User. Return ErrUserNotFound when the User does not exist.
+	FindUserByPhoneNumber(ctx context.Context, phoneNumber domain.PhoneNumber) (Identity, error)
+
+	// 3. The auto-registration policy, as in RequestPhoneNumberLogin.
+	WhenUserNotFound(ctx context.Context, phoneNumber domain.PhoneNumber) error
+
+	// 4. Create the User with the verified phone number.
+	CreateUserByPhoneNumber(ctx context.Context, phoneNumber domain.PhoneNumber) (Identity, error)
+}
+
+type LoginPhoneNumberDto struct {
+	PhoneNumber   string `example:"+601243567890" desc:"Phone number in E164 format"`
+	IdempotentKey string `desc:"unique key to ensure the request is unique, e.g. using the md5 hash of the request"`
+	OTP           string `example:"123456"`
+	DeviceID      string `desc:"Optional identifier of the device or browser, e.g. from a cookie"`
+	UserAgent     string `desc:"Optional client user agent"`
+}
+
+func (d LoginPhoneNumberDto) Validate() error {
+	return d.verifyOtpDto().Validate()
+}
+
+func (d LoginPhoneNumberDto) verifyOtpDto() otp.VerifyOtpDto {
+	return otp.VerifyOtpDto{
+		PhoneNumber:   d.PhoneNumber,
+		IdempotentKey: d.IdempotentKey,
+		Topic:         LoginTopic,
+		OTP:           d.OTP,
+	}
+}
+
+func LoginPhoneNumber(ctx context.Context, steps loginPhoneNumber, dto LoginPhoneNumberDto) (Identity, error) {
+	if err := domain.Validate(dto); err != nil {
+		return Identity{}, err
+	}
+
+	if err := otp.VerifyOtp(ctx, steps, dto.verifyOtpDto()); err != nil {
+		return Identity{}, err
+	}
+
+	attempt := LoginDto{
+		DeviceID:  dto.DeviceID,
+		UserAgent: dto.UserAgent,
+	}
+
+	phoneNumber := domain.PhoneNumber(dto.PhoneNumber)
+	id, err := steps.FindUserByPhoneNumber(ctx, phoneNumber)
+	if err == nil {
+		if err := loginIdentity(ctx, steps, id, attempt); err != nil {
+			return Identity{}, err
+		}
+
+		return id, nil
+	}
+
+	if !errors.Is(err, ErrUserNotFound) {
+		return Identity{}, err
+	}
+
+	if err := steps.WhenUserNotFound(ctx, phoneNumber); err != nil {
+		return Identity{}, err
+	}
+
+	id, err = steps.CreateUserByPhoneNumber(ctx, phoneNumber)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	if err := rememberIdentity(ctx, steps, id, attempt); err != nil {
+		return Identity{}, err
+	}
+
+	return id, nil
+}
@@ -0,0 +1,232 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/auth"
+	"github.com/alextanhongpin/go-service-oriented-package/app/otp"
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	errOtpMismatch      = errors.New("otp mismatch")
+	errRegistrationOff  = errors.New("registration closed")
+	errSessionNotFound  = errors.New("session not found")
+	phoneNumber         = domain.PhoneNumber("+60123456789")
+	unknownPhoneNumber  = domain.PhoneNumber("+60129876543")
+	phoneLoginIdentity  = auth.Identity{UserID: "1", PhoneNumber: phoneNumber}
+	phoneLoginCreatedID = "2"
+)
+
+type otpSession struct {
+	dto otp.SendOtpDto
+	otp domain.OTP
+}
+
+type phoneLoginStub struct {
+	autoRegister bool
+	users        map[domain.PhoneNumber]auth.Identity
+	sessions     map[string]otpSession
+}
+
+func newPhoneLoginStub(autoRegister bool) *phoneLoginStub {
+	return &phoneLoginStub{
+		autoRegister: autoRegister,
+		users: map[domain.PhoneNumber]auth.Identity{
+			phoneNumber: phoneLoginIdentity,
+		},
+		sessions: make(map[string]otpSession),
+	}
+}
+
+func (s *phoneLoginStub) FindUserByPhoneNumber(ctx context.Context, phoneNumber domain.PhoneNumber) (auth.Identity, error) {
+	id, ok := s.users[phoneNumber]
+	if !ok {
+		return auth.Identity{}, auth.ErrUserNotFound
+	}
+
+	return id, nil
+}
+
+func (s *phoneLoginStub) WhenUserNotFound(ctx context.Context, phoneNumber domain.PhoneNumber) error {
+	if !s.autoRegister {
+		return errRegistrationOff
+	}
+
+	return nil
+}
+
+func (s *phoneLoginStub) CreateUserByPhoneNumber(ctx context.Context, phoneNumber domain.PhoneNumber) (auth.Identity, error) {
+	id := auth.Identity{UserID: phoneLoginCreatedID, PhoneNumber: phoneNumber}
+	s.users[phoneNumber] = id
+	return id, nil
+}
+
+func (s *phoneLoginStub) Allow(ctx context.Context, dto otp.SendOtpDto) error {
+	return nil
+}
+
+func (s *phoneLoginStub) GenerateOtp(ctx context.Context) (domain.OTP, error) {
+	return "123456", nil
+}
+
+func (s *phoneLoginStub) CreateSession(ctx context.Context, dto otp.SendOtpDto, code domain.OTP) error {
+	s.sessions[dto.Topic+":"+dto.PhoneNumber] = otpSession{dto: dto, otp: code}
+	return nil
+}
+
+func (s *phoneLoginStub) SendMessage(ctx context.Context, dto otp.SendOtpDto, code domain.OTP) error {
+	return nil
+}
+
+func (s *phoneLoginStub) Verify(ctx context.Context, dto otp.VerifyOtpDto) (string, error) {
+	sess, ok := s.sessions[dto.Topic+":"+dto.PhoneNumber]
+	if !ok {
+		return "", errSessionNotFound
+	}
+
+	if sess.otp != domain.OTP(dto.OTP) {
+		return "", errOtpMismatch
+	}
+
+	return sess.dto.IdempotentKey, nil
+}
+
+func (s *phoneLoginStub) ClearSession(ctx context.Context, dto otp.VerifyOtpDto) error {
+	delete(s.sessions, dto.Topic+":"+dto.PhoneNumber)
+	return nil
+}
+
+type phoneLoginSecondFactorStub struct {
+	*phoneLoginStub
+	enrolled bool
+	attempt  auth.LoginAttempt
+}
+
+func (s *phoneLoginSecondFactorStub) HasSecondFactor(ctx context.Context, email domain.Email) (bool, error) {
+	return s.enrolled, nil
+}
+
+func (s *phoneLoginSecondFactorStub) CreateSecondFactorChallenge(ctx context.Context, email domain.Email, attempt auth.LoginAttempt) (string, error) {
+	s.attempt = attempt
+	return "challenge", nil
+}
+
+func TestPhoneNumberLogin(t *testing.T) {
+	ctx := context.Background()
+
+	type steps interface {
+		FindUserByPhoneNumber(ctx context.Context, phoneNumber domain.PhoneNumber) (auth.Identity, error)
+		WhenUserNotFound(ctx context.Context, phoneNumber domain.PhoneNumber) error
+		CreateUserByPhoneNumber(ctx context.Context, phoneNumber domain.PhoneNumber) (auth.Identity, error)
+		Allow(ctx context.Context, dto otp.SendOtpDto) error
+		GenerateOtp(ctx context.Context) (domain.OTP, error)
+		CreateSession(ctx context.Context, dto otp.SendOtpDto, otp domain.OTP) error
+		SendMessage(ctx context.Context, dto otp.SendOtpDto, otp domain.OTP) error
+		Verify(ctx context.Context, dto otp.VerifyOtpDto) (string, error)
+		ClearSession(ctx context.Context, dto otp.VerifyOtpDto) error
+	}
+
+	login := func(t *testing.T, steps steps, phone domain.PhoneNumber, code string) (auth.Identity, error) {
+		t.Helper()
+
+		err := auth.RequestPhoneNumberLogin(ctx, steps, auth.RequestPhoneNumberLoginDto{
+			PhoneNumber:   phone.String(),
+			IdempotentKey: "key",
+		})
+		if err != nil {
+			return auth.Identity{}, err
+		}
+
+		return auth.LoginPhoneNumber(ctx, steps, auth.LoginPhoneNumberDto{
+			PhoneNumber:   phone.String(),
+			IdempotentKey: "key",
+			OTP:           code,
+			DeviceID:      "device-1",
+		})
+	}
+
+	t.Run("existing user", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newPhoneLoginStub(false)
+		id, err := login(t, steps, phoneNumber, "123456")
+		assert.Nil(err)
+		assert.Equal(phoneLoginIdentity, id)
+		assert.Empty(steps.sessions)
+	})
+
+	t.Run("second factor", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := &phoneLoginSecondFactorStub{phoneLoginStub: newPhoneLoginStub(false), enrolled: true}
+		steps.users[phoneNumber] = auth.Identity{UserID: "1", Email: "john.doe@mail.com", PhoneNumber: phoneNumber}
+
+		_, err := login(t, steps, phoneNumber, "123456")
+
+		var sfErr *auth.SecondFactorRequiredError
+		assert.True(errors.As(err, &sfErr))
+		assert.Equal("challenge", sfErr.Challenge)
+		assert.Equal(domain.NewDeviceFingerprint("device-1", ""), steps.attempt.Fingerprint)
+	})
+
+	t.Run("second factor not enrolled", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := &phoneLoginSecondFactorStub{phoneLoginStub: newPhoneLoginStub(false)}
+		steps.users[phoneNumber] = auth.Identity{UserID: "1", Email: "john.doe@mail.com", PhoneNumber: phoneNumber}
+
+		id, err := login(t, steps, phoneNumber, "123456")
+		assert.Nil(err)
+		assert.Equal("1", id.UserID)
+	})
+
+	t.Run("otp mismatch", func(t *testing.T) {
+		steps := newPhoneLoginStub(false)
+		_, err := login(t, steps, phoneNumber, "654321")
+		assert.ErrorIs(t, err, errOtpMismatch)
+	})
+
+	t.Run("request modified", func(t *testing.T) {
+		steps := newPhoneLoginStub(false)
+		err := auth.RequestPhoneNumberLogin(ctx, steps, auth.RequestPhoneNumberLoginDto{
+			PhoneNumber:   phoneNumber.String(),
+			IdempotentKey: "key",
+		})
+		assert.Nil(t, err)
+
+		_, err = auth.LoginPhoneNumber(ctx, steps, auth.LoginPhoneNumberDto{
+			PhoneNumber:   phoneNumber.String(),
+			IdempotentKey: "other-key",
+			OTP:           "123456",
+		})
+		assert.ErrorIs(t, err, otp.ErrRequestModified)
+	})
+
+	t.Run("auto registration", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newPhoneLoginStub(true)
+		id, err := login(t, steps, unknownPhoneNumber, "123456")
+		assert.Nil(err)
+		assert.Equal(auth.Identity{UserID: phoneLoginCreatedID, PhoneNumber: unknownPhoneNumber}, id)
+	})
+
+	t.Run("registration closed", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newPhoneLoginStub(false)
+		_, err := login(t, steps, unknownPhoneNumber, "123456")
+		assert.ErrorIs(err, errRegistrationOff)
+		assert.Empty(steps.sessions)
+	})
+
+	t.Run("invalid phone number", func(t *testing.T) {
+		steps := newPhoneLoginStub(true)
+		_, err := login(t, steps, "123", "123456")
+		assert.ErrorIs(t, err, domain.ErrInvalidPhoneNumber)
+	})
+}
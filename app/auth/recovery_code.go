@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+)
+
+// Flow to generate new recovery codes for a logged-in User. The codes are only
+// returned once, and previously generated codes are no longer valid.
+type generateRecoveryCodes interface {
+	// 1. Replace the existing recovery codes with the new ones.
+	SaveRecoveryCodes(ctx context.Context, email domain.Email, codes []domain.Ciphertext) error
+}
+
+type GenerateRecoveryCodesDto struct {
+	Email string
+}
+
+func (d GenerateRecoveryCodesDto) Validate() error {
+	return domain.Email(d.Email).Validate()
+}
+
+func GenerateRecoveryCodes(ctx context.Context, steps generateRecoveryCodes, dto GenerateRecoveryCodesDto) ([]domain.RecoveryCode, error) {
+	if err := domain.Validate(dto); err != nil {
+		return nil, err
+	}
+
+	codes, err := domain.NewRecoveryCodes(domain.RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertexts := make([]domain.Ciphertext, len(codes))
+	for i, code := range codes {
+		ciphertexts[i], err = code.Encrypt()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	email := domain.Email(dto.Email)
+	if err := steps.SaveRecoveryCodes(ctx, email, ciphertexts); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Second stage of Login, using a recovery code instead of the TOTP
+// authenticator. Like LoginTOTP, implement the steps of loginLockout to limit
+// the guesses for the email.
+type loginRecoveryCode interface {
+	// 1. Verify the challenge returned by Login. It should have the email as the
+	// subject, and the attempt passed to CreateSecondFactorChallenge.
+	VerifySecondFactorChallenge(ctx context.Context, challenge string) (domain.Email, LoginAttempt, error)
+
+	// 2. Load the unused recovery codes.
+	FindRecoveryCodesByEmail(ctx context.Context, email domain.Email) ([]domain.Ciphertext, error)
+
+	// 3. What error to return if none of the recovery codes match?
+	WhenRecoveryCodeMatch(ctx context.Context, match bool) error
+
+	// 4. Delete the recovery code, so that it cannot be used again. Only called
+	// when a recovery code matches.
+	DeleteRecoveryCode(ctx context.Context, email domain.Email, code domain.Ciphertext) error
+}
+
+type LoginRecoveryCodeDto struct {
+	Challenge string
+	Code      string
+}
+
+func (d LoginRecoveryCodeDto) Validate() error {
+	if d.Challenge == "" {
+		return errors.New("auth: challenge required")
+	}
+
+	return domain.RecoveryCode(d.Code).Validate()
+}
+
+func LoginRecoveryCode(ctx context.Context, steps loginRecoveryCode, dto LoginRecoveryCodeDto) error {
+	if err := domain.Validate(dto); err != nil {
+		return err
+	}
+
+	email, attempt, err := steps.VerifySecondFactorChallenge(ctx, dto.Challenge)
+	if err != nil {
+		return err
+	}
+
+	lockout, hasLockout := steps.(loginLockout)
+	if hasLockout {
+		if err := checkLockout(ctx, lockout, email, ""); err != nil {
+			return err
+		}
+	}
+
+	ciphertexts, err := steps.FindRecoveryCodesByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	code := domain.RecoveryCode(dto.Code)
+
+	var used domain.Ciphertext
+	for _, c := range ciphertexts {
+		if code.Match(c) {
+			used = c
+			break
+		}
+	}
+
+	match := used != ""
+	if hasLockout {
+		if err := countFailedLogins(ctx, lockout, email, "", match); err != nil {
+			return err
+		}
+	}
+
+	if err := steps.WhenRecoveryCodeMatch(ctx, match); err != nil {
+		return err
+	}
+
+	if match {
+		if err := steps.DeleteRecoveryCode(ctx, email, used); err != nil {
+			return err
+		}
+	}
+
+	return rememberLogin(ctx, steps, email, attempt)
+}
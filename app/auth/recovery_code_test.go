@@ -0,0 +1,119 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/auth"
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var errRecoveryCodeMismatch = errors.New("recovery code mismatch")
+
+type recoveryCodeStub struct {
+	codes   []domain.Ciphertext
+	deleted int
+}
+
+func (s *recoveryCodeStub) SaveRecoveryCodes(ctx context.Context, email domain.Email, codes []domain.Ciphertext) error {
+	s.codes = codes
+	return nil
+}
+
+func (s *recoveryCodeStub) VerifySecondFactorChallenge(ctx context.Context, challenge string) (domain.Email, auth.LoginAttempt, error) {
+	return "john.doe@mail.com", auth.LoginAttempt{}, nil
+}
+
+func (s *recoveryCodeStub) FindRecoveryCodesByEmail(ctx context.Context, email domain.Email) ([]domain.Ciphertext, error) {
+	return s.codes, nil
+}
+
+func (s *recoveryCodeStub) WhenRecoveryCodeMatch(ctx context.Context, match bool) error {
+	if !match {
+		return errRecoveryCodeMismatch
+	}
+
+	return nil
+}
+
+func (s *recoveryCodeStub) DeleteRecoveryCode(ctx context.Context, email domain.Email, code domain.Ciphertext) error {
+	s.deleted++
+	for i, c := range s.codes {
+		if c == code {
+			s.codes = append(s.codes[:i], s.codes[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+// recoveryCodeFlagStub allows mismatched codes, e.g. to flag them instead.
+type recoveryCodeFlagStub struct {
+	*recoveryCodeStub
+}
+
+func (s *recoveryCodeFlagStub) WhenRecoveryCodeMatch(ctx context.Context, match bool) error {
+	return nil
+}
+
+type recoveryCodeLockoutStub struct {
+	*recoveryCodeStub
+	*loginLockoutStub
+}
+
+func TestRecoveryCode(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	steps := new(recoveryCodeStub)
+
+	codes, err := auth.GenerateRecoveryCodes(ctx, steps, auth.GenerateRecoveryCodesDto{
+		Email: "john.doe@mail.com",
+	})
+	assert.Nil(err)
+	assert.Len(codes, domain.RecoveryCodeCount)
+	assert.Len(steps.codes, domain.RecoveryCodeCount)
+
+	dto := auth.LoginRecoveryCodeDto{
+		Challenge: "challenge",
+		Code:      string(codes[len(codes)-1]),
+	}
+	assert.Nil(auth.LoginRecoveryCode(ctx, steps, dto))
+	assert.Len(steps.codes, domain.RecoveryCodeCount-1)
+
+	// Recovery codes are single-use.
+	assert.ErrorIs(auth.LoginRecoveryCode(ctx, steps, dto), errRecoveryCodeMismatch)
+	assert.Equal(1, steps.deleted)
+
+	// Nothing is deleted when a mismatch is allowed.
+	assert.Nil(auth.LoginRecoveryCode(ctx, &recoveryCodeFlagStub{steps}, dto))
+	assert.Equal(1, steps.deleted)
+}
+
+func TestLoginRecoveryCodeLockout(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	steps := &recoveryCodeLockoutStub{
+		recoveryCodeStub: new(recoveryCodeStub),
+		loginLockoutStub: newLoginLockoutStub(),
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(ctx, steps, auth.GenerateRecoveryCodesDto{
+		Email: "john.doe@mail.com",
+	})
+	assert.Nil(err)
+
+	wrong := auth.LoginRecoveryCodeDto{Challenge: "challenge", Code: "aaaaa-aaaaa-aaaaa-aaaaa"}
+	for i := 0; i < 3; i++ {
+		assert.ErrorIs(auth.LoginRecoveryCode(ctx, steps, wrong), errRecoveryCodeMismatch)
+	}
+
+	// The correct code is rejected while locked out.
+	dto := auth.LoginRecoveryCodeDto{Challenge: "challenge", Code: string(codes[0])}
+	assert.ErrorIs(auth.LoginRecoveryCode(ctx, steps, dto), auth.ErrLockedOut)
+	assert.Len(steps.codes, domain.RecoveryCodeCount)
+}
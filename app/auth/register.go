@@ -37,6 +37,15 @@ func Register(ctx context.Context, steps register, dto RegisterDto) error {
 
 	name := dto.Name
 	email := domain.Email(dto.Email)
+	password := domain.Plaintext(dto.Password)
+
+	if err := checkPasswordPolicy(steps, password, email, name); err != nil {
+		return err
+	}
+
+	if err := checkPasswordBreached(ctx, steps, password); err != nil {
+		return err
+	}
 
 	exists, err := steps.CheckEmailExists(ctx, email)
 	if err != nil {
@@ -47,11 +56,14 @@ func Register(ctx context.Context, steps register, dto RegisterDto) error {
 		return err
 	}
 
-	password := domain.Plaintext(dto.Password)
 	ciphertext, err := password.Encrypt()
 	if err != nil {
 		return err
 	}
 
-	return steps.CreateUser(ctx, name, email, ciphertext)
+	if err := steps.CreateUser(ctx, name, email, ciphertext); err != nil {
+		return err
+	}
+
+	return savePasswordHistory(ctx, steps, email, ciphertext)
 }
@@ -0,0 +1,140 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/auth"
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	errEmailExists      = errors.New("email exists")
+	errPasswordBreached = errors.New("password breached")
+)
+
+type registerStub struct {
+	exists  bool
+	created domain.Ciphertext
+}
+
+func (s *registerStub) CheckEmailExists(ctx context.Context, email domain.Email) (bool, error) {
+	return s.exists, nil
+}
+
+func (s *registerStub) WhenEmailExists(ctx context.Context, exists bool) error {
+	if exists {
+		return errEmailExists
+	}
+
+	return nil
+}
+
+func (s *registerStub) CreateUser(ctx context.Context, name string, email domain.Email, ciphertext domain.Ciphertext) error {
+	s.created = ciphertext
+	return nil
+}
+
+type registerPolicyStub struct {
+	registerStub
+	policy domain.PasswordPolicy
+}
+
+func (s *registerPolicyStub) PasswordPolicy() domain.PasswordPolicy {
+	return s.policy
+}
+
+type registerBreachStub struct {
+	registerStub
+	checker domain.BreachChecker
+}
+
+func (s *registerBreachStub) IsPasswordBreached(ctx context.Context, password domain.Plaintext) (bool, error) {
+	return s.checker.Breached(ctx, password)
+}
+
+func (s *registerBreachStub) WhenPasswordBreached(ctx context.Context, breached bool) error {
+	if breached {
+		return errPasswordBreached
+	}
+
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	dto := auth.RegisterDto{
+		Name:     "John Doe",
+		Email:    "john.doe@mail.com",
+		Password: "12345678",
+	}
+
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		steps := new(registerStub)
+		assert.Nil(t, auth.Register(ctx, steps, dto))
+		assert.True(t, steps.created.Compare("12345678"))
+	})
+
+	t.Run("email exists", func(t *testing.T) {
+		steps := &registerStub{exists: true}
+		assert.ErrorIs(t, auth.Register(ctx, steps, dto), errEmailExists)
+		assert.Empty(t, steps.created)
+	})
+
+	t.Run("password policy", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := &registerPolicyStub{
+			policy: domain.PasswordPolicy{
+				RequireUpper:       true,
+				RejectPersonalInfo: true,
+			},
+		}
+
+		dto := dto
+		dto.Password = "johnny12345"
+		err := auth.Register(ctx, steps, dto)
+		assert.ErrorIs(err, domain.ErrPasswordMissingUpper)
+		assert.ErrorIs(err, domain.ErrPasswordPersonalInfo)
+		assert.Empty(steps.created)
+
+		dto.Password = "Tr0ub4dor&3"
+		assert.Nil(auth.Register(ctx, steps, dto))
+		assert.NotEmpty(steps.created)
+	})
+
+	t.Run("default password policy", func(t *testing.T) {
+		assert := assert.New(t)
+
+		policy := domain.DefaultPasswordPolicy
+		t.Cleanup(func() {
+			domain.DefaultPasswordPolicy = policy
+		})
+
+		domain.DefaultPasswordPolicy.RequireUpper = true
+
+		steps := new(registerStub)
+		assert.ErrorIs(auth.Register(ctx, steps, dto), domain.ErrPasswordMissingUpper)
+		assert.Empty(steps.created)
+	})
+
+	t.Run("password breached", func(t *testing.T) {
+		assert := assert.New(t)
+
+		bf, err := domain.NewBloomFilter(1, 0.001)
+		assert.Nil(err)
+		bf.Add("12345678")
+
+		steps := &registerBreachStub{checker: bf}
+		assert.ErrorIs(auth.Register(ctx, steps, dto), errPasswordBreached)
+		assert.Empty(steps.created)
+
+		dto := dto
+		dto.Password = "Tr0ub4dor&3"
+		assert.Nil(auth.Register(ctx, steps, dto))
+		assert.NotEmpty(steps.created)
+	})
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"time"
 
 	"github.com/alextanhongpin/go-service-oriented-package/domain"
 )
@@ -11,18 +12,40 @@ type requestResetPassword interface {
 	// 1. Checks if the email exists.
 	CheckEmailExists(ctx context.Context, email domain.Email) (bool, error)
 
-	// 2. What error to return if the email do/do not exists?
+	// 2. What error to return if the email do/do not exists? Not called with
+	// the steps of requestResetPasswordSafe, but still required, so that the
+	// flow without them always decides how to respond to unknown emails.
 	WhenEmailExists(ctx context.Context, exists bool) error
 
-	// 3. Generate a jwt token with the email as the subject.
-	// Add expiration when needed.
-	GenerateToken(ctx context.Context, email domain.Email) (string, error)
+	// 3. Load the current security stamp of the User, see
+	// domain.SecurityStamp.
+	FindSecurityStamp(ctx context.Context, email domain.Email) (domain.SecurityStamp, error)
 
-	// 4. Send the email containing the reset password link and token to the
+	// 4. Generate a token with the email as the subject and the stamp, e.g.
+	// with tokens.Tokens.IssueStamped. Add expiration when needed.
+	GenerateToken(ctx context.Context, email domain.Email, stamp domain.SecurityStamp) (string, error)
+
+	// 5. Send the email containing the reset password link and token to the
 	// email.
 	SendResetPasswordEmail(ctx context.Context, email domain.Email, token string) error
 }
 
+// Optional steps for RequestResetPassword to not reveal which accounts exist.
+// The flow always reports success, and WhenEmailExists is not called.
+type requestResetPasswordSafe interface {
+	// 1. Send a notice to an email without an account, that someone tried to
+	// reset the password for this address.
+	SendUnknownAccountEmail(ctx context.Context, email domain.Email) error
+
+	// 2. The minimum duration of the flow, so that the response time does not
+	// reveal if the email exists.
+	MinResponseTime() time.Duration
+
+	// 3. Log or report the errors of the flow. They are not returned, since
+	// some steps are only called for known or unknown emails.
+	ReportError(ctx context.Context, email domain.Email, err error)
+}
+
 type RequestResetPasswordDto struct {
 	Email string
 }
@@ -37,18 +60,57 @@ func RequestResetPassword(ctx context.Context, steps requestResetPassword, dto R
 	}
 
 	email := domain.Email(dto.Email)
+	safe, isSafe := steps.(requestResetPasswordSafe)
+	if !isSafe {
+		return sendResetPasswordEmail(ctx, steps, nil, email)
+	}
+
+	defer padResponseTime(ctx, time.Now(), safe.MinResponseTime())
+
+	if err := sendResetPasswordEmail(ctx, steps, safe, email); err != nil {
+		safe.ReportError(ctx, email, err)
+	}
+
+	return nil
+}
+
+// sendResetPasswordEmail sends the notice to unknown emails when the safe
+// steps are given, or calls WhenEmailExists otherwise.
+func sendResetPasswordEmail(ctx context.Context, steps requestResetPassword, safe requestResetPasswordSafe, email domain.Email) error {
 	exists, err := steps.CheckEmailExists(ctx, email)
 	if err != nil {
 		return err
 	}
-	if err := steps.WhenEmailExists(ctx, exists); err != nil {
+
+	if safe != nil {
+		if !exists {
+			return safe.SendUnknownAccountEmail(ctx, email)
+		}
+	} else if err := steps.WhenEmailExists(ctx, exists); err != nil {
+		return err
+	}
+
+	stamp, err := steps.FindSecurityStamp(ctx, email)
+	if err != nil {
 		return err
 	}
 
-	token, err := steps.GenerateToken(ctx, email)
+	token, err := steps.GenerateToken(ctx, email, stamp)
 	if err != nil {
 		return err
 	}
 
 	return steps.SendResetPasswordEmail(ctx, email, token)
 }
+
+// padResponseTime waits until d has passed since start, or the context is
+// done.
+func padResponseTime(ctx context.Context, start time.Time, d time.Duration) {
+	t := time.NewTimer(time.Until(start.Add(d)))
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
@@ -7,18 +7,34 @@ import (
 	"github.com/alextanhongpin/go-service-oriented-package/domain"
 )
 
+// ErrTokenRevoked is returned by ResetPassword when the password changed
+// after the token was issued.
+var ErrTokenRevoked = errors.New("auth: token revoked")
+
 // A continuation of the request reset password for non-logged in user.
 type resetPassword interface {
-	// 1. Verify the jwt token provided. It should have the email as the subject.
-	VerifyToken(ctx context.Context, token string) (domain.Email, error)
+	// 1. Verify the token provided, without using it, e.g. with
+	// tokens.Tokens.Verify. It should have the email as the subject, and the
+	// security stamp when it was issued.
+	VerifyToken(ctx context.Context, token string) (domain.Email, domain.SecurityStamp, error)
+
+	// 2. Load the current security stamp of the User. The token is revoked
+	// if the stamp changed, e.g. after ChangePassword.
+	FindSecurityStamp(ctx context.Context, email domain.Email) (domain.SecurityStamp, error)
 
-	// 2. Encrypt the password, and update the password for the given email.
+	// 3. Mark the token as used, e.g. with tokens.Tokens.Use, so that it
+	// cannot be reused. Only called after the new password passes the checks,
+	// so that a rejected password does not burn the token.
+	ConsumeToken(ctx context.Context, token string) error
+
+	// 4. Encrypt the password, and update the password for the given email.
 	UpdatePassword(ctx context.Context, email domain.Email, newPassword domain.Ciphertext) error
 }
 
 type ResetPasswordDto struct {
 	Token       string
 	NewPassword string
+	SessionID   string `desc:"Optional current session, that is kept when other sessions are revoked"`
 }
 
 func (d ResetPasswordDto) Validate() error {
@@ -34,16 +50,60 @@ func ResetPassword(ctx context.Context, steps resetPassword, dto ResetPasswordDt
 		return err
 	}
 
-	email, err := steps.VerifyToken(ctx, dto.Token)
+	email, tokenStamp, err := steps.VerifyToken(ctx, dto.Token)
+	if err != nil {
+		return err
+	}
+
+	stamp, err := steps.FindSecurityStamp(ctx, email)
 	if err != nil {
 		return err
 	}
 
+	if !stamp.Equal(tokenStamp) {
+		return ErrTokenRevoked
+	}
+
 	pwd := domain.Plaintext(dto.NewPassword)
+	if err := checkPasswordPolicy(steps, pwd, email, ""); err != nil {
+		return err
+	}
+
+	if s, ok := steps.(passwordReuse); ok {
+		reused, err := isPasswordInHistory(ctx, s, email, pwd)
+		if err != nil {
+			return err
+		}
+
+		if err := s.WhenPasswordIsReused(ctx, reused); err != nil {
+			return err
+		}
+	}
+
+	if err := checkPasswordBreached(ctx, steps, pwd); err != nil {
+		return err
+	}
+
+	if err := steps.ConsumeToken(ctx, dto.Token); err != nil {
+		return err
+	}
+
 	newPwd, err := pwd.Encrypt()
 	if err != nil {
 		return err
 	}
 
-	return steps.UpdatePassword(ctx, email, newPwd)
+	if err := steps.UpdatePassword(ctx, email, newPwd); err != nil {
+		return err
+	}
+
+	if err := rotateStamp(ctx, steps, email); err != nil {
+		return err
+	}
+
+	if err := savePasswordHistory(ctx, steps, email, newPwd); err != nil {
+		return err
+	}
+
+	return revokeSessions(ctx, steps, email, dto.SessionID)
 }
@@ -0,0 +1,347 @@
+package auth_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/auth"
+	"github.com/alextanhongpin/go-service-oriented-package/app/tokens"
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var errEmailNotFound = errors.New("email not found")
+
+const resetPasswordPurpose = "reset_password"
+
+// resetPasswordStub binds the tokens to the stamp of the current ciphertext.
+type resetPasswordStub struct {
+	tokens  *tokens.Tokens
+	current domain.Ciphertext
+	sent    string
+}
+
+func newResetPasswordStub(t *testing.T) *resetPasswordStub {
+	t.Helper()
+
+	tk, err := tokens.New(tokens.Key{ID: "1", Secret: bytes.Repeat([]byte("a"), 32)})
+	assert.Nil(t, err)
+	tk.Nonces = tokens.NewMemoryNonceStore()
+
+	return &resetPasswordStub{tokens: tk, current: ciphertext}
+}
+
+func (s *resetPasswordStub) CheckEmailExists(ctx context.Context, email domain.Email) (bool, error) {
+	return email == "john.doe@mail.com", nil
+}
+
+func (s *resetPasswordStub) WhenEmailExists(ctx context.Context, exists bool) error {
+	if !exists {
+		return errEmailNotFound
+	}
+
+	return nil
+}
+
+func (s *resetPasswordStub) FindSecurityStamp(ctx context.Context, email domain.Email) (domain.SecurityStamp, error) {
+	return s.current.Stamp(), nil
+}
+
+func (s *resetPasswordStub) GenerateToken(ctx context.Context, email domain.Email, stamp domain.SecurityStamp) (string, error) {
+	return s.tokens.IssueStamped(string(email), resetPasswordPurpose, string(stamp), time.Hour)
+}
+
+func (s *resetPasswordStub) SendResetPasswordEmail(ctx context.Context, email domain.Email, token string) error {
+	s.sent = token
+	return nil
+}
+
+func (s *resetPasswordStub) VerifyToken(ctx context.Context, token string) (domain.Email, domain.SecurityStamp, error) {
+	c, err := s.tokens.Verify(token, resetPasswordPurpose)
+	if err != nil {
+		return "", "", err
+	}
+
+	return domain.Email(c.Subject), domain.SecurityStamp(c.Stamp), nil
+}
+
+func (s *resetPasswordStub) ConsumeToken(ctx context.Context, token string) error {
+	_, err := s.tokens.Use(ctx, token, resetPasswordPurpose)
+	return err
+}
+
+func (s *resetPasswordStub) UpdatePassword(ctx context.Context, email domain.Email, newPassword domain.Ciphertext) error {
+	s.current = newPassword
+	return nil
+}
+
+// Authenticate and WhenPasswordIsReused implement the steps of ChangePassword.
+func (s *resetPasswordStub) Authenticate(ctx context.Context, email domain.Email, oldPassword domain.Plaintext) error {
+	if !s.current.Compare(oldPassword) {
+		return errPasswordMismatch
+	}
+
+	return nil
+}
+
+func (s *resetPasswordStub) WhenPasswordIsReused(ctx context.Context, isPasswordReused bool) error {
+	if isPasswordReused {
+		return errPasswordReused
+	}
+
+	return nil
+}
+
+// resetPasswordStampStub stores the stamp instead of deriving it from the
+// ciphertext.
+type resetPasswordStampStub struct {
+	*resetPasswordStub
+	stamp domain.SecurityStamp
+}
+
+func (s *resetPasswordStampStub) FindSecurityStamp(ctx context.Context, email domain.Email) (domain.SecurityStamp, error) {
+	return s.stamp, nil
+}
+
+func (s *resetPasswordStampStub) UpdateSecurityStamp(ctx context.Context, email domain.Email, stamp domain.SecurityStamp) error {
+	s.stamp = stamp
+	return nil
+}
+
+type resetPasswordHistoryStub struct {
+	*resetPasswordStub
+	historyStub
+}
+
+func (s *resetPasswordHistoryStub) WhenPasswordIsReused(ctx context.Context, isPasswordReused bool) error {
+	if isPasswordReused {
+		return errPasswordReused
+	}
+
+	return nil
+}
+
+type requestResetPasswordSafeStub struct {
+	*resetPasswordStub
+	unknown  domain.Email
+	sendErr  error
+	reported []error
+}
+
+func (s *requestResetPasswordSafeStub) SendUnknownAccountEmail(ctx context.Context, email domain.Email) error {
+	if s.sendErr != nil {
+		return s.sendErr
+	}
+
+	s.unknown = email
+	return nil
+}
+
+func (s *requestResetPasswordSafeStub) ReportError(ctx context.Context, email domain.Email, err error) {
+	s.reported = append(s.reported, err)
+}
+
+func (s *requestResetPasswordSafeStub) MinResponseTime() time.Duration {
+	return 50 * time.Millisecond
+}
+
+type resetPasswordRevokeStub struct {
+	*resetPasswordStub
+	revokeSessionsStub
+}
+
+func requestResetPassword(t *testing.T, steps *resetPasswordStub) string {
+	t.Helper()
+
+	dto := auth.RequestResetPasswordDto{Email: "john.doe@mail.com"}
+	assert.Nil(t, auth.RequestResetPassword(context.Background(), steps, dto))
+	assert.NotEmpty(t, steps.sent)
+
+	return steps.sent
+}
+
+func TestRequestResetPassword(t *testing.T) {
+	steps := newResetPasswordStub(t)
+
+	dto := auth.RequestResetPasswordDto{Email: "jane.doe@mail.com"}
+	assert.ErrorIs(t, auth.RequestResetPassword(context.Background(), steps, dto), errEmailNotFound)
+	assert.Empty(t, steps.sent)
+}
+
+func TestRequestResetPasswordSafe(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("unknown email", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := &requestResetPasswordSafeStub{resetPasswordStub: newResetPasswordStub(t)}
+
+		start := time.Now()
+		dto := auth.RequestResetPasswordDto{Email: "jane.doe@mail.com"}
+		assert.Nil(auth.RequestResetPassword(ctx, steps, dto))
+		assert.GreaterOrEqual(time.Since(start), steps.MinResponseTime())
+		assert.Equal(domain.Email("jane.doe@mail.com"), steps.unknown)
+		assert.Empty(steps.sent)
+	})
+
+	t.Run("known email", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := &requestResetPasswordSafeStub{resetPasswordStub: newResetPasswordStub(t)}
+
+		start := time.Now()
+		dto := auth.RequestResetPasswordDto{Email: "john.doe@mail.com"}
+		assert.Nil(auth.RequestResetPassword(ctx, steps, dto))
+		assert.GreaterOrEqual(time.Since(start), steps.MinResponseTime())
+		assert.Empty(steps.unknown)
+		assert.NotEmpty(steps.sent)
+	})
+
+	t.Run("errors reported", func(t *testing.T) {
+		assert := assert.New(t)
+
+		errSend := errors.New("send failed")
+		steps := &requestResetPasswordSafeStub{resetPasswordStub: newResetPasswordStub(t), sendErr: errSend}
+
+		dto := auth.RequestResetPasswordDto{Email: "jane.doe@mail.com"}
+		assert.Nil(auth.RequestResetPassword(ctx, steps, dto))
+		assert.Equal([]error{errSend}, steps.reported)
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		steps := &requestResetPasswordSafeStub{resetPasswordStub: newResetPasswordStub(t)}
+
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		start := time.Now()
+		dto := auth.RequestResetPasswordDto{Email: "jane.doe@mail.com"}
+		assert.Nil(t, auth.RequestResetPassword(ctx, steps, dto))
+		assert.Less(t, time.Since(start), steps.MinResponseTime())
+	})
+}
+
+func TestResetPassword(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newResetPasswordStub(t)
+		dto := auth.ResetPasswordDto{
+			Token:       requestResetPassword(t, steps),
+			NewPassword: "correcthorse",
+		}
+		assert.Nil(auth.ResetPassword(ctx, steps, dto))
+		assert.True(steps.current.Compare("correcthorse"))
+
+		// The password changed, so the token is revoked.
+		assert.ErrorIs(auth.ResetPassword(ctx, steps, dto), auth.ErrTokenRevoked)
+	})
+
+	t.Run("token used", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newResetPasswordStub(t)
+		dto := auth.ResetPasswordDto{
+			Token:       requestResetPassword(t, steps),
+			NewPassword: "correcthorse",
+		}
+		assert.Nil(steps.ConsumeToken(ctx, dto.Token))
+		assert.ErrorIs(auth.ResetPassword(ctx, steps, dto), tokens.ErrTokenUsed)
+		assert.Equal(ciphertext, steps.current)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		steps := newResetPasswordStub(t)
+		dto := auth.ResetPasswordDto{
+			Token:       "invalid-token",
+			NewPassword: "correcthorse",
+		}
+		assert.ErrorIs(t, auth.ResetPassword(ctx, steps, dto), tokens.ErrTokenInvalid)
+		assert.Equal(t, ciphertext, steps.current)
+	})
+
+	t.Run("revoked after password change", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newResetPasswordStub(t)
+		token := requestResetPassword(t, steps)
+
+		assert.Nil(auth.ChangePassword(ctx, steps, auth.ChangePasswordDto{
+			Email:       "john.doe@mail.com",
+			OldPassword: string(password),
+			NewPassword: "87654321",
+		}))
+		changed := steps.current
+
+		dto := auth.ResetPasswordDto{
+			Token:       token,
+			NewPassword: "correcthorse",
+		}
+		assert.ErrorIs(auth.ResetPassword(ctx, steps, dto), auth.ErrTokenRevoked)
+		assert.Equal(changed, steps.current)
+	})
+
+	t.Run("stored stamp", func(t *testing.T) {
+		assert := assert.New(t)
+
+		stamp, err := domain.NewSecurityStamp()
+		assert.Nil(err)
+
+		steps := &resetPasswordStampStub{resetPasswordStub: newResetPasswordStub(t), stamp: stamp}
+		assert.Nil(auth.RequestResetPassword(ctx, steps, auth.RequestResetPasswordDto{Email: "john.doe@mail.com"}))
+		token := steps.sent
+
+		assert.Nil(auth.ChangePassword(ctx, steps, auth.ChangePasswordDto{
+			Email:       "john.doe@mail.com",
+			OldPassword: string(password),
+			NewPassword: "87654321",
+		}))
+		assert.NotEqual(stamp, steps.stamp)
+
+		dto := auth.ResetPasswordDto{
+			Token:       token,
+			NewPassword: "correcthorse",
+		}
+		assert.ErrorIs(auth.ResetPassword(ctx, steps, dto), auth.ErrTokenRevoked)
+	})
+
+	t.Run("password history", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := &resetPasswordHistoryStub{
+			resetPasswordStub: newResetPasswordStub(t),
+			historyStub:       historyStub{history: []domain.Ciphertext{ciphertext}},
+		}
+
+		dto := auth.ResetPasswordDto{
+			Token:       requestResetPassword(t, steps.resetPasswordStub),
+			NewPassword: string(password),
+		}
+		assert.ErrorIs(auth.ResetPassword(ctx, steps, dto), errPasswordReused)
+		assert.Equal(ciphertext, steps.current)
+
+		// The rejected password does not use the token.
+		dto.NewPassword = "correcthorse"
+		assert.Nil(auth.ResetPassword(ctx, steps, dto))
+		assert.Len(steps.history, 2)
+		assert.True(steps.history[1].Compare("correcthorse"))
+	})
+
+	t.Run("revoke all sessions", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := &resetPasswordRevokeStub{resetPasswordStub: newResetPasswordStub(t)}
+		dto := auth.ResetPasswordDto{
+			Token:       requestResetPassword(t, steps.resetPasswordStub),
+			NewPassword: "correcthorse",
+		}
+		assert.Nil(auth.ResetPassword(ctx, steps, dto))
+		assert.True(steps.revoked)
+		assert.Empty(steps.kept)
+	})
+}
@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+)
+
+// Optional step for flows that update the password, to logout the User
+// everywhere else, e.g. with sessions.RevokeAll.
+type revokeOtherSessions interface {
+	// 1. Revoke all sessions and tokens of the User, except the session with
+	// keepSessionID. All sessions are revoked when it is empty.
+	RevokeOtherSessions(ctx context.Context, email domain.Email, keepSessionID string) error
+}
+
+func revokeSessions(ctx context.Context, steps any, email domain.Email, keepSessionID string) error {
+	s, ok := steps.(revokeOtherSessions)
+	if !ok {
+		return nil
+	}
+
+	return s.RevokeOtherSessions(ctx, email, keepSessionID)
+}
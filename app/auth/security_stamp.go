@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+)
+
+// Optional step for flows that change the credentials, when the steps store a
+// domain.SecurityStamp per User instead of deriving it with
+// domain.Ciphertext.Stamp. Tokens issued with the previous stamp are revoked.
+type rotateSecurityStamp interface {
+	// 1. Replace the stored stamp of the User.
+	UpdateSecurityStamp(ctx context.Context, email domain.Email, stamp domain.SecurityStamp) error
+}
+
+func rotateStamp(ctx context.Context, steps any, email domain.Email) error {
+	s, ok := steps.(rotateSecurityStamp)
+	if !ok {
+		return nil
+	}
+
+	stamp, err := domain.NewSecurityStamp()
+	if err != nil {
+		return err
+	}
+
+	return s.UpdateSecurityStamp(ctx, email, stamp)
+}
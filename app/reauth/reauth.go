@@ -0,0 +1,187 @@
+// Package reauth requires a recent authentication before sensitive
+// operations, e.g. payouts or email changes. Reauthenticate records a marker
+// for the session, and RequireFresh checks that the marker is not stale.
+package reauth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+)
+
+// DefaultMaxAge is how long a reauthentication stays fresh.
+const DefaultMaxAge = 5 * time.Minute
+
+// Authentication methods.
+const (
+	MethodPassword = "password"
+	MethodOTP      = "otp"
+)
+
+// Reauth errors.
+var (
+	// ErrReauthRequired is returned by RequireFresh when there is no recent
+	// authentication.
+	ErrReauthRequired = errors.New("reauth: recent authentication required")
+
+	ErrUserIDRequired     = errors.New("reauth: user id required")
+	ErrSessionIDRequired  = errors.New("reauth: session id required")
+	ErrCredentialRequired = errors.New("reauth: either password or otp required")
+)
+
+// StaleError carries the last authentication time, so that the client can
+// prompt the User to reauthenticate.
+type StaleError struct {
+	// AuthenticatedAt is zero if the session never reauthenticated.
+	AuthenticatedAt time.Time
+	MaxAge          time.Duration
+}
+
+func (e *StaleError) Error() string {
+	return ErrReauthRequired.Error()
+}
+
+func (e *StaleError) Unwrap() error {
+	return ErrReauthRequired
+}
+
+// Marker records when the session last authenticated.
+type Marker struct {
+	Method          string
+	AuthenticatedAt time.Time
+	MaxAge          time.Duration
+}
+
+// Fresh returns true if the marker is younger than the max age at t. The
+// stricter of the marker and the given max age applies, and the given max age
+// is ignored when it is zero.
+func (m Marker) Fresh(t time.Time, maxAge time.Duration) bool {
+	if m.AuthenticatedAt.IsZero() {
+		return false
+	}
+
+	return t.Before(m.AuthenticatedAt.Add(m.stricter(maxAge)))
+}
+
+func (m Marker) stricter(maxAge time.Duration) time.Duration {
+	if maxAge > 0 && (m.MaxAge <= 0 || maxAge < m.MaxAge) {
+		return maxAge
+	}
+
+	return m.MaxAge
+}
+
+// Flow for a logged-in User to authenticate again, with either the password
+// or an OTP.
+type reauthenticate interface {
+	// 1a. Verify the password of the User.
+	VerifyPassword(ctx context.Context, userID string, password domain.Plaintext) error
+
+	// 1b. Verify the OTP of the User, e.g. with otp.VerifyOtp or a TOTP.
+	VerifyOTP(ctx context.Context, userID string, otp domain.OTP) error
+
+	// 2. How long the reauthentication stays fresh, e.g. DefaultMaxAge.
+	MaxAge() time.Duration
+
+	// 3. Save the marker for the session.
+	SaveMarker(ctx context.Context, sessionID string, m Marker) error
+}
+
+type ReauthenticateDto struct {
+	UserID    string
+	SessionID string
+	Password  string `desc:"Either password or otp"`
+	OTP       string `desc:"Either password or otp"`
+}
+
+func (d ReauthenticateDto) Validate() error {
+	if d.UserID == "" {
+		return ErrUserIDRequired
+	}
+
+	if d.SessionID == "" {
+		return ErrSessionIDRequired
+	}
+
+	if (d.Password == "") == (d.OTP == "") {
+		return ErrCredentialRequired
+	}
+
+	if d.OTP != "" {
+		return domain.OTP(d.OTP).Validate()
+	}
+
+	return domain.Plaintext(d.Password).Validate()
+}
+
+func Reauthenticate(ctx context.Context, steps reauthenticate, dto ReauthenticateDto) (Marker, error) {
+	if err := domain.Validate(dto); err != nil {
+		return Marker{}, err
+	}
+
+	method := MethodPassword
+	if dto.OTP != "" {
+		method = MethodOTP
+		if err := steps.VerifyOTP(ctx, dto.UserID, domain.OTP(dto.OTP)); err != nil {
+			return Marker{}, err
+		}
+	} else if err := steps.VerifyPassword(ctx, dto.UserID, domain.Plaintext(dto.Password)); err != nil {
+		return Marker{}, err
+	}
+
+	m := Marker{
+		Method:          method,
+		AuthenticatedAt: time.Now(),
+		MaxAge:          steps.MaxAge(),
+	}
+	if err := steps.SaveMarker(ctx, dto.SessionID, m); err != nil {
+		return Marker{}, err
+	}
+
+	return m, nil
+}
+
+// Guard for flows that require a recent authentication. Call it from a step
+// of the flow, e.g. otp.SendOtp's Allow.
+type requireFresh interface {
+	// 1. Load the marker of the session. The zero value should be returned
+	// when there is none.
+	FindMarker(ctx context.Context, sessionID string) (Marker, error)
+}
+
+type RequireFreshDto struct {
+	SessionID string
+	MaxAge    time.Duration `desc:"Optional stricter max age for the operation"`
+}
+
+func (d RequireFreshDto) Validate() error {
+	if d.SessionID == "" {
+		return ErrSessionIDRequired
+	}
+
+	return nil
+}
+
+// RequireFresh returns a *StaleError when the session did not authenticate
+// recently.
+func RequireFresh(ctx context.Context, steps requireFresh, dto RequireFreshDto) error {
+	if err := domain.Validate(dto); err != nil {
+		return err
+	}
+
+	m, err := steps.FindMarker(ctx, dto.SessionID)
+	if err != nil {
+		return err
+	}
+
+	if m.Fresh(time.Now(), dto.MaxAge) {
+		return nil
+	}
+
+	return &StaleError{
+		AuthenticatedAt: m.AuthenticatedAt,
+		MaxAge:          m.stricter(dto.MaxAge),
+	}
+}
@@ -0,0 +1,201 @@
+package reauth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/reauth"
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	errPasswordMismatch = errors.New("password mismatch")
+	errOTPMismatch      = errors.New("otp mismatch")
+)
+
+type reauthStub struct {
+	markers map[string]reauth.Marker
+}
+
+func newReauthStub() *reauthStub {
+	return &reauthStub{markers: make(map[string]reauth.Marker)}
+}
+
+func (s *reauthStub) VerifyPassword(ctx context.Context, userID string, password domain.Plaintext) error {
+	if password != "12345678" {
+		return errPasswordMismatch
+	}
+
+	return nil
+}
+
+func (s *reauthStub) VerifyOTP(ctx context.Context, userID string, otp domain.OTP) error {
+	if otp != "123456" {
+		return errOTPMismatch
+	}
+
+	return nil
+}
+
+func (s *reauthStub) MaxAge() time.Duration {
+	return reauth.DefaultMaxAge
+}
+
+func (s *reauthStub) SaveMarker(ctx context.Context, sessionID string, m reauth.Marker) error {
+	s.markers[sessionID] = m
+	return nil
+}
+
+func (s *reauthStub) FindMarker(ctx context.Context, sessionID string) (reauth.Marker, error) {
+	return s.markers[sessionID], nil
+}
+
+func TestReauthenticate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("password", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newReauthStub()
+		m, err := reauth.Reauthenticate(ctx, steps, reauth.ReauthenticateDto{
+			UserID:    "1",
+			SessionID: "session-1",
+			Password:  "12345678",
+		})
+		assert.Nil(err)
+		assert.Equal(reauth.MethodPassword, m.Method)
+		assert.Equal(reauth.DefaultMaxAge, m.MaxAge)
+		assert.Equal(m, steps.markers["session-1"])
+	})
+
+	t.Run("otp", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newReauthStub()
+		m, err := reauth.Reauthenticate(ctx, steps, reauth.ReauthenticateDto{
+			UserID:    "1",
+			SessionID: "session-1",
+			OTP:       "123456",
+		})
+		assert.Nil(err)
+		assert.Equal(reauth.MethodOTP, m.Method)
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newReauthStub()
+		_, err := reauth.Reauthenticate(ctx, steps, reauth.ReauthenticateDto{
+			UserID:    "1",
+			SessionID: "session-1",
+			Password:  "87654321",
+		})
+		assert.ErrorIs(err, errPasswordMismatch)
+
+		_, err = reauth.Reauthenticate(ctx, steps, reauth.ReauthenticateDto{
+			UserID:    "1",
+			SessionID: "session-1",
+			OTP:       "654321",
+		})
+		assert.ErrorIs(err, errOTPMismatch)
+		assert.Empty(steps.markers)
+	})
+
+	t.Run("either password or otp", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newReauthStub()
+		_, err := reauth.Reauthenticate(ctx, steps, reauth.ReauthenticateDto{
+			UserID:    "1",
+			SessionID: "session-1",
+		})
+		assert.ErrorIs(err, reauth.ErrCredentialRequired)
+
+		_, err = reauth.Reauthenticate(ctx, steps, reauth.ReauthenticateDto{
+			UserID:    "1",
+			SessionID: "session-1",
+			Password:  "12345678",
+			OTP:       "123456",
+		})
+		assert.ErrorIs(err, reauth.ErrCredentialRequired)
+	})
+}
+
+func TestRequireFresh(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("never authenticated", func(t *testing.T) {
+		assert := assert.New(t)
+
+		err := reauth.RequireFresh(ctx, newReauthStub(), reauth.RequireFreshDto{SessionID: "session-1"})
+		assert.ErrorIs(err, reauth.ErrReauthRequired)
+
+		var staleErr *reauth.StaleError
+		assert.True(errors.As(err, &staleErr))
+		assert.True(staleErr.AuthenticatedAt.IsZero())
+	})
+
+	t.Run("fresh", func(t *testing.T) {
+		steps := newReauthStub()
+		_, err := reauth.Reauthenticate(ctx, steps, reauth.ReauthenticateDto{
+			UserID:    "1",
+			SessionID: "session-1",
+			Password:  "12345678",
+		})
+		assert.Nil(t, err)
+
+		assert.Nil(t, reauth.RequireFresh(ctx, steps, reauth.RequireFreshDto{SessionID: "session-1"}))
+
+		// The marker is per session.
+		err = reauth.RequireFresh(ctx, steps, reauth.RequireFreshDto{SessionID: "session-2"})
+		assert.ErrorIs(t, err, reauth.ErrReauthRequired)
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		assert := assert.New(t)
+
+		authenticatedAt := time.Now().Add(-reauth.DefaultMaxAge)
+		steps := newReauthStub()
+		steps.markers["session-1"] = reauth.Marker{
+			Method:          reauth.MethodPassword,
+			AuthenticatedAt: authenticatedAt,
+			MaxAge:          reauth.DefaultMaxAge,
+		}
+
+		err := reauth.RequireFresh(ctx, steps, reauth.RequireFreshDto{SessionID: "session-1"})
+
+		var staleErr *reauth.StaleError
+		assert.True(errors.As(err, &staleErr))
+		assert.Equal(authenticatedAt, staleErr.AuthenticatedAt)
+		assert.Equal(reauth.DefaultMaxAge, staleErr.MaxAge)
+	})
+
+	t.Run("stricter max age", func(t *testing.T) {
+		assert := assert.New(t)
+
+		steps := newReauthStub()
+		steps.markers["session-1"] = reauth.Marker{
+			Method:          reauth.MethodPassword,
+			AuthenticatedAt: time.Now().Add(-2 * time.Minute),
+			MaxAge:          reauth.DefaultMaxAge,
+		}
+
+		assert.Nil(reauth.RequireFresh(ctx, steps, reauth.RequireFreshDto{SessionID: "session-1"}))
+
+		err := reauth.RequireFresh(ctx, steps, reauth.RequireFreshDto{SessionID: "session-1", MaxAge: time.Minute})
+		var staleErr *reauth.StaleError
+		assert.True(errors.As(err, &staleErr))
+		assert.Equal(time.Minute, staleErr.MaxAge)
+
+		// A looser max age does not extend the marker.
+		steps.markers["session-1"] = reauth.Marker{
+			AuthenticatedAt: time.Now().Add(-10 * time.Minute),
+			MaxAge:          reauth.DefaultMaxAge,
+		}
+		err = reauth.RequireFresh(ctx, steps, reauth.RequireFreshDto{SessionID: "session-1", MaxAge: time.Hour})
+		assert.ErrorIs(err, reauth.ErrReauthRequired)
+	})
+}
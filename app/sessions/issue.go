@@ -0,0 +1,84 @@
+package sessions
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// Flow to create a session after the User logs in.
+type issue interface {
+	// 1. Persist the session.
+	CreateSession(ctx context.Context, s Session) error
+
+	// 2. Persist the refresh token.
+	CreateRefreshToken(ctx context.Context, rt RefreshToken) error
+
+	// 3. Generate a short-lived access token for the session, e.g. a JWT with
+	// the user id as the subject and the session id as a claim.
+	GenerateAccessToken(ctx context.Context, s Session) (token string, expiresAt time.Time, err error)
+}
+
+type IssueDto struct {
+	UserID    string
+	UserAgent string `desc:"Optional client user agent"`
+	IPAddress string `example:"203.0.113.1" desc:"Optional client IP address"`
+}
+
+func (d IssueDto) Validate() error {
+	if d.UserID == "" {
+		return ErrUserIDRequired
+	}
+
+	if d.IPAddress != "" && net.ParseIP(d.IPAddress) == nil {
+		return ErrInvalidIPAddress
+	}
+
+	return nil
+}
+
+func Issue(ctx context.Context, steps issue, dto IssueDto) (Tokens, error) {
+	if err := dto.Validate(); err != nil {
+		return Tokens{}, err
+	}
+
+	id, err := randomString(sessionIDLen)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	now := time.Now()
+	refreshToken, rt, err := newRefreshToken(id, now)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	s := Session{
+		ID:         id,
+		UserID:     dto.UserID,
+		UserAgent:  dto.UserAgent,
+		IPAddress:  dto.IPAddress,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  rt.ExpiresAt,
+	}
+	if err := steps.CreateSession(ctx, s); err != nil {
+		return Tokens{}, err
+	}
+
+	if err := steps.CreateRefreshToken(ctx, rt); err != nil {
+		return Tokens{}, err
+	}
+
+	accessToken, expiresAt, err := steps.GenerateAccessToken(ctx, s)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	return Tokens{
+		SessionID:            id,
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: expiresAt,
+		RefreshToken:         refreshToken,
+	}, nil
+}
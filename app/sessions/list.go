@@ -0,0 +1,47 @@
+package sessions
+
+import (
+	"context"
+	"time"
+)
+
+// Flow to list the active sessions of the User, e.g. to show the logged in
+// devices.
+type list interface {
+	// 1. Load the sessions of the User. The revoked and expired sessions are
+	// filtered out by the flow.
+	FindSessionsByUserID(ctx context.Context, userID string) ([]Session, error)
+}
+
+type ListDto struct {
+	UserID string
+}
+
+func (d ListDto) Validate() error {
+	if d.UserID == "" {
+		return ErrUserIDRequired
+	}
+
+	return nil
+}
+
+func List(ctx context.Context, steps list, dto ListDto) ([]Session, error) {
+	if err := dto.Validate(); err != nil {
+		return nil, err
+	}
+
+	sessions, err := steps.FindSessionsByUserID(ctx, dto.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	active := make([]Session, 0, len(sessions))
+	for _, s := range sessions {
+		if s.Active(now) {
+			active = append(active, s)
+		}
+	}
+
+	return active, nil
+}
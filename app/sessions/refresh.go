@@ -0,0 +1,127 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Flow to rotate the refresh token, and issue a new access token.
+type refresh interface {
+	// 1. Load the refresh token by hash. Return ErrNotFound when it does not
+	// exist.
+	FindRefreshToken(ctx context.Context, hash string) (RefreshToken, error)
+
+	// 2. Load the session of the refresh token.
+	FindSession(ctx context.Context, id string) (Session, error)
+
+	// 3a. Revoke the session and all its refresh tokens, when a rotated
+	// refresh token is reused.
+	RevokeSession(ctx context.Context, id string) error
+
+	// 3b. Mark the refresh token as used. This must be atomic, and return
+	// false if it is already used, e.g. by a concurrent request.
+	UseRefreshToken(ctx context.Context, hash string) (bool, error)
+
+	// 4. Persist the next refresh token, and extend the session.
+	CreateRefreshToken(ctx context.Context, rt RefreshToken) error
+	TouchSession(ctx context.Context, id string, lastUsedAt, expiresAt time.Time) error
+
+	// 5. Generate a short-lived access token for the session.
+	GenerateAccessToken(ctx context.Context, s Session) (token string, expiresAt time.Time, err error)
+}
+
+type RefreshDto struct {
+	RefreshToken string
+}
+
+func (d RefreshDto) Validate() error {
+	if d.RefreshToken == "" {
+		return ErrRefreshTokenRequired
+	}
+
+	return nil
+}
+
+func Refresh(ctx context.Context, steps refresh, dto RefreshDto) (Tokens, error) {
+	if err := dto.Validate(); err != nil {
+		return Tokens{}, err
+	}
+
+	hash := HashRefreshToken(dto.RefreshToken)
+	rt, err := steps.FindRefreshToken(ctx, hash)
+	if errors.Is(err, ErrNotFound) {
+		return Tokens{}, ErrRefreshTokenInvalid
+	}
+
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	s, err := steps.FindSession(ctx, rt.SessionID)
+	if errors.Is(err, ErrNotFound) {
+		return Tokens{}, ErrRefreshTokenInvalid
+	}
+
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if s.Revoked() {
+		return Tokens{}, ErrSessionRevoked
+	}
+
+	if rt.Used {
+		return Tokens{}, revokeReused(ctx, steps, s.ID)
+	}
+
+	now := time.Now()
+	if !now.Before(rt.ExpiresAt) {
+		return Tokens{}, ErrRefreshTokenExpired
+	}
+
+	ok, err := steps.UseRefreshToken(ctx, hash)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if !ok {
+		return Tokens{}, revokeReused(ctx, steps, s.ID)
+	}
+
+	refreshToken, next, err := newRefreshToken(s.ID, now)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if err := steps.CreateRefreshToken(ctx, next); err != nil {
+		return Tokens{}, err
+	}
+
+	if err := steps.TouchSession(ctx, s.ID, now, next.ExpiresAt); err != nil {
+		return Tokens{}, err
+	}
+
+	s.LastUsedAt = now
+	s.ExpiresAt = next.ExpiresAt
+
+	accessToken, expiresAt, err := steps.GenerateAccessToken(ctx, s)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	return Tokens{
+		SessionID:            s.ID,
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: expiresAt,
+		RefreshToken:         refreshToken,
+	}, nil
+}
+
+func revokeReused(ctx context.Context, steps refresh, id string) error {
+	if err := steps.RevokeSession(ctx, id); err != nil {
+		return err
+	}
+
+	return ErrRefreshTokenReused
+}
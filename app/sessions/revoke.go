@@ -0,0 +1,81 @@
+package sessions
+
+import (
+	"context"
+)
+
+// Flow to revoke a session of the User, e.g. to logout or to remove a device.
+type revoke interface {
+	// 1. Load the session. Return ErrNotFound when it does not exist.
+	FindSession(ctx context.Context, id string) (Session, error)
+
+	// 2. Revoke the session and all its refresh tokens.
+	RevokeSession(ctx context.Context, id string) error
+}
+
+type RevokeDto struct {
+	UserID    string
+	SessionID string
+}
+
+func (d RevokeDto) Validate() error {
+	if d.UserID == "" {
+		return ErrUserIDRequired
+	}
+
+	if d.SessionID == "" {
+		return ErrSessionIDRequired
+	}
+
+	return nil
+}
+
+func Revoke(ctx context.Context, steps revoke, dto RevokeDto) error {
+	if err := dto.Validate(); err != nil {
+		return err
+	}
+
+	s, err := steps.FindSession(ctx, dto.SessionID)
+	if err != nil {
+		return err
+	}
+
+	// Do not reveal sessions of other Users.
+	if s.UserID != dto.UserID {
+		return ErrNotFound
+	}
+
+	if s.Revoked() {
+		return nil
+	}
+
+	return steps.RevokeSession(ctx, s.ID)
+}
+
+// Flow to revoke all sessions of the User, e.g. to logout everywhere.
+type revokeAll interface {
+	// 1. Revoke the sessions of the User, and all their refresh tokens,
+	// except the session with exceptSessionID when it is not empty.
+	RevokeSessionsByUserID(ctx context.Context, userID, exceptSessionID string) error
+}
+
+type RevokeAllDto struct {
+	UserID          string
+	ExceptSessionID string `desc:"Optional current session to keep"`
+}
+
+func (d RevokeAllDto) Validate() error {
+	if d.UserID == "" {
+		return ErrUserIDRequired
+	}
+
+	return nil
+}
+
+func RevokeAll(ctx context.Context, steps revokeAll, dto RevokeAllDto) error {
+	if err := dto.Validate(); err != nil {
+		return err
+	}
+
+	return steps.RevokeSessionsByUserID(ctx, dto.UserID, dto.ExceptSessionID)
+}
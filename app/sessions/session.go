@@ -0,0 +1,113 @@
+// Package sessions issues sessions after the User is authenticated, e.g. by
+// the auth flows. A session has a short-lived access token, and a refresh
+// token that is rotated on every use.
+package sessions
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+const (
+	sessionIDLen    = 16
+	refreshTokenLen = 32
+)
+
+// RefreshTokenTTL is the lifetime of a refresh token. Since the refresh token
+// is rotated on every use, the session expires after being idle for this
+// duration.
+var RefreshTokenTTL = 30 * 24 * time.Hour
+
+// Session errors.
+var (
+	// ErrNotFound should be returned by steps that look up a session or
+	// refresh token that does not exist.
+	ErrNotFound = errors.New("sessions: not found")
+
+	ErrUserIDRequired       = errors.New("sessions: user id required")
+	ErrSessionIDRequired    = errors.New("sessions: session id required")
+	ErrInvalidIPAddress     = errors.New("sessions: invalid ip address")
+	ErrRefreshTokenRequired = errors.New("sessions: refresh token required")
+	ErrRefreshTokenInvalid  = errors.New("sessions: invalid refresh token")
+	ErrRefreshTokenExpired  = errors.New("sessions: refresh token expired")
+
+	// ErrRefreshTokenReused is returned by Refresh when a rotated refresh token
+	// is used again. It is likely stolen, so the session is revoked.
+	ErrRefreshTokenReused = errors.New("sessions: refresh token reused")
+	ErrSessionRevoked     = errors.New("sessions: session revoked")
+)
+
+// Session is a login of the User on a device. All the refresh tokens rotated
+// from the same login belong to the session, and are revoked together.
+type Session struct {
+	ID         string
+	UserID     string
+	UserAgent  string
+	IPAddress  string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+	RevokedAt  time.Time
+}
+
+// Revoked returns true if the session is revoked.
+func (s Session) Revoked() bool {
+	return !s.RevokedAt.IsZero()
+}
+
+// Active returns true if the session is neither revoked nor expired at t.
+func (s Session) Active(t time.Time) bool {
+	return !s.Revoked() && t.Before(s.ExpiresAt)
+}
+
+// RefreshToken is the stored refresh token. Only the hash of the token is
+// stored.
+type RefreshToken struct {
+	Hash      string
+	SessionID string
+	ExpiresAt time.Time
+
+	// Used is true once the refresh token is rotated.
+	Used bool
+}
+
+// Tokens are returned to the client.
+type Tokens struct {
+	SessionID            string
+	AccessToken          string
+	AccessTokenExpiresAt time.Time
+	RefreshToken         string
+}
+
+// HashRefreshToken returns the hash of the refresh token that is stored.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// newRefreshToken returns the refresh token for the client, and the stored
+// refresh token.
+func newRefreshToken(sessionID string, now time.Time) (string, RefreshToken, error) {
+	token, err := randomString(refreshTokenLen)
+	if err != nil {
+		return "", RefreshToken{}, err
+	}
+
+	return token, RefreshToken{
+		Hash:      HashRefreshToken(token),
+		SessionID: sessionID,
+		ExpiresAt: now.Add(RefreshTokenTTL),
+	}, nil
+}
@@ -0,0 +1,318 @@
+package sessions_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+// store is an in-memory implementation of the steps.
+type store struct {
+	mu            sync.Mutex
+	sessions      map[string]sessions.Session
+	refreshTokens map[string]sessions.RefreshToken
+}
+
+func newStore() *store {
+	return &store{
+		sessions:      make(map[string]sessions.Session),
+		refreshTokens: make(map[string]sessions.RefreshToken),
+	}
+}
+
+func (s *store) CreateSession(ctx context.Context, sess sessions.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[sess.ID] = sess
+	return nil
+}
+
+func (s *store) CreateRefreshToken(ctx context.Context, rt sessions.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshTokens[rt.Hash] = rt
+	return nil
+}
+
+func (s *store) GenerateAccessToken(ctx context.Context, sess sessions.Session) (string, time.Time, error) {
+	return "access:" + sess.ID, time.Now().Add(15 * time.Minute), nil
+}
+
+func (s *store) FindRefreshToken(ctx context.Context, hash string) (sessions.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rt, ok := s.refreshTokens[hash]
+	if !ok {
+		return sessions.RefreshToken{}, sessions.ErrNotFound
+	}
+
+	return rt, nil
+}
+
+func (s *store) FindSession(ctx context.Context, id string) (sessions.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[id]
+	if !ok {
+		return sessions.Session{}, sessions.ErrNotFound
+	}
+
+	return sess, nil
+}
+
+func (s *store) RevokeSession(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revoke(id)
+	return nil
+}
+
+func (s *store) UseRefreshToken(ctx context.Context, hash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rt := s.refreshTokens[hash]
+	if rt.Used {
+		return false, nil
+	}
+
+	rt.Used = true
+	s.refreshTokens[hash] = rt
+	return true, nil
+}
+
+func (s *store) TouchSession(ctx context.Context, id string, lastUsedAt, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess := s.sessions[id]
+	sess.LastUsedAt = lastUsedAt
+	sess.ExpiresAt = expiresAt
+	s.sessions[id] = sess
+	return nil
+}
+
+func (s *store) FindSessionsByUserID(ctx context.Context, userID string) ([]sessions.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []sessions.Session
+	for _, sess := range s.sessions {
+		if sess.UserID == userID {
+			res = append(res, sess)
+		}
+	}
+
+	return res, nil
+}
+
+func (s *store) RevokeSessionsByUserID(ctx context.Context, userID, exceptSessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, sess := range s.sessions {
+		if sess.UserID == userID && id != exceptSessionID {
+			s.revoke(id)
+		}
+	}
+
+	return nil
+}
+
+func (s *store) revoke(id string) {
+	sess := s.sessions[id]
+	if !sess.Revoked() {
+		sess.RevokedAt = time.Now()
+	}
+	s.sessions[id] = sess
+
+	for hash, rt := range s.refreshTokens {
+		if rt.SessionID == id {
+			delete(s.refreshTokens, hash)
+		}
+	}
+}
+
+func issue(t *testing.T, s *store, userID string) sessions.Tokens {
+	t.Helper()
+
+	tokens, err := sessions.Issue(context.Background(), s, sessions.IssueDto{
+		UserID:    userID,
+		UserAgent: "Mozilla/5.0",
+		IPAddress: "203.0.113.1",
+	})
+	assert.Nil(t, err)
+
+	return tokens
+}
+
+func TestIssue(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newStore()
+	tokens := issue(t, s, "user-1")
+	assert.NotEmpty(tokens.SessionID)
+	assert.Equal("access:"+tokens.SessionID, tokens.AccessToken)
+	assert.NotEmpty(tokens.RefreshToken)
+	assert.True(tokens.AccessTokenExpiresAt.After(time.Now()))
+
+	// Only the hash of the refresh token is stored.
+	_, ok := s.refreshTokens[tokens.RefreshToken]
+	assert.False(ok)
+	_, ok = s.refreshTokens[sessions.HashRefreshToken(tokens.RefreshToken)]
+	assert.True(ok)
+
+	sess := s.sessions[tokens.SessionID]
+	assert.Equal("user-1", sess.UserID)
+	assert.Equal("Mozilla/5.0", sess.UserAgent)
+	assert.True(sess.Active(time.Now()))
+
+	_, err := sessions.Issue(context.Background(), s, sessions.IssueDto{})
+	assert.ErrorIs(err, sessions.ErrUserIDRequired)
+
+	_, err = sessions.Issue(context.Background(), s, sessions.IssueDto{UserID: "user-1", IPAddress: "invalid"})
+	assert.ErrorIs(err, sessions.ErrInvalidIPAddress)
+}
+
+func TestRefresh(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("rotate", func(t *testing.T) {
+		assert := assert.New(t)
+
+		s := newStore()
+		tokens := issue(t, s, "user-1")
+
+		next, err := sessions.Refresh(ctx, s, sessions.RefreshDto{RefreshToken: tokens.RefreshToken})
+		assert.Nil(err)
+		assert.Equal(tokens.SessionID, next.SessionID)
+		assert.NotEqual(tokens.RefreshToken, next.RefreshToken)
+
+		next, err = sessions.Refresh(ctx, s, sessions.RefreshDto{RefreshToken: next.RefreshToken})
+		assert.Nil(err)
+		assert.Equal(tokens.SessionID, next.SessionID)
+	})
+
+	t.Run("reuse revokes the session", func(t *testing.T) {
+		assert := assert.New(t)
+
+		s := newStore()
+		tokens := issue(t, s, "user-1")
+		other := issue(t, s, "user-1")
+
+		next, err := sessions.Refresh(ctx, s, sessions.RefreshDto{RefreshToken: tokens.RefreshToken})
+		assert.Nil(err)
+
+		_, err = sessions.Refresh(ctx, s, sessions.RefreshDto{RefreshToken: tokens.RefreshToken})
+		assert.ErrorIs(err, sessions.ErrRefreshTokenReused)
+		assert.True(s.sessions[tokens.SessionID].Revoked())
+
+		// The latest refresh token of the session is revoked too.
+		_, err = sessions.Refresh(ctx, s, sessions.RefreshDto{RefreshToken: next.RefreshToken})
+		assert.ErrorIs(err, sessions.ErrRefreshTokenInvalid)
+
+		// Other sessions are not affected.
+		_, err = sessions.Refresh(ctx, s, sessions.RefreshDto{RefreshToken: other.RefreshToken})
+		assert.Nil(err)
+	})
+
+	t.Run("concurrent reuse", func(t *testing.T) {
+		assert := assert.New(t)
+
+		s := newStore()
+		tokens := issue(t, s, "user-1")
+
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = sessions.Refresh(ctx, s, sessions.RefreshDto{RefreshToken: tokens.RefreshToken})
+			}(i)
+		}
+		wg.Wait()
+
+		// At most one succeeds, and the session is revoked either way.
+		assert.False(errs[0] == nil && errs[1] == nil)
+		assert.True(s.sessions[tokens.SessionID].Revoked())
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		s := newStore()
+		tokens := issue(t, s, "user-1")
+
+		hash := sessions.HashRefreshToken(tokens.RefreshToken)
+		rt := s.refreshTokens[hash]
+		rt.ExpiresAt = time.Now().Add(-time.Second)
+		s.refreshTokens[hash] = rt
+
+		_, err := sessions.Refresh(ctx, s, sessions.RefreshDto{RefreshToken: tokens.RefreshToken})
+		assert.ErrorIs(t, err, sessions.ErrRefreshTokenExpired)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		s := newStore()
+		_, err := sessions.Refresh(ctx, s, sessions.RefreshDto{RefreshToken: "invalid"})
+		assert.ErrorIs(t, err, sessions.ErrRefreshTokenInvalid)
+
+		_, err = sessions.Refresh(ctx, s, sessions.RefreshDto{})
+		assert.ErrorIs(t, err, sessions.ErrRefreshTokenRequired)
+	})
+}
+
+func TestListAndRevoke(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	s := newStore()
+	t1 := issue(t, s, "user-1")
+	t2 := issue(t, s, "user-1")
+	t3 := issue(t, s, "user-2")
+
+	active, err := sessions.List(ctx, s, sessions.ListDto{UserID: "user-1"})
+	assert.Nil(err)
+	assert.Len(active, 2)
+
+	// Sessions of other Users cannot be revoked.
+	err = sessions.Revoke(ctx, s, sessions.RevokeDto{UserID: "user-1", SessionID: t3.SessionID})
+	assert.ErrorIs(err, sessions.ErrNotFound)
+
+	assert.Nil(sessions.Revoke(ctx, s, sessions.RevokeDto{UserID: "user-1", SessionID: t1.SessionID}))
+	assert.Nil(sessions.Revoke(ctx, s, sessions.RevokeDto{UserID: "user-1", SessionID: t1.SessionID}))
+
+	active, err = sessions.List(ctx, s, sessions.ListDto{UserID: "user-1"})
+	assert.Nil(err)
+	assert.Len(active, 1)
+	assert.Equal(t2.SessionID, active[0].ID)
+
+	_, err = sessions.Refresh(ctx, s, sessions.RefreshDto{RefreshToken: t1.RefreshToken})
+	assert.ErrorIs(err, sessions.ErrRefreshTokenInvalid)
+
+	t4 := issue(t, s, "user-1")
+	assert.Nil(sessions.RevokeAll(ctx, s, sessions.RevokeAllDto{UserID: "user-1", ExceptSessionID: t4.SessionID}))
+
+	active, err = sessions.List(ctx, s, sessions.ListDto{UserID: "user-1"})
+	assert.Nil(err)
+	assert.Len(active, 1)
+	assert.Equal(t4.SessionID, active[0].ID)
+
+	assert.Nil(sessions.RevokeAll(ctx, s, sessions.RevokeAllDto{UserID: "user-1"}))
+
+	active, err = sessions.List(ctx, s, sessions.ListDto{UserID: "user-1"})
+	assert.Nil(err)
+	assert.Empty(active)
+
+	active, err = sessions.List(ctx, s, sessions.ListDto{UserID: "user-2"})
+	assert.Nil(err)
+	assert.Len(active, 1)
+}
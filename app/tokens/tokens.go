@@ -0,0 +1,247 @@
+// Package tokens issues HMAC-signed tokens for links sent to the User, e.g.
+// reset password and email verification. A token carries the subject,
+// purpose, expiry and a random nonce, and can be made single-use with a
+// NonceStore.
+package tokens
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const nonceLen = 16
+
+// Token errors.
+var (
+	ErrKeyInvalid      = errors.New("tokens: invalid key")
+	ErrKeyNotFound     = errors.New("tokens: key not found")
+	ErrTokenInvalid    = errors.New("tokens: invalid token")
+	ErrTokenExpired    = errors.New("tokens: token expired")
+	ErrTokenUsed       = errors.New("tokens: token already used")
+	ErrPurposeMismatch = errors.New("tokens: purpose mismatch")
+)
+
+// Key is a signing key. The ID is embedded in the token, so that tokens
+// signed with a previous key can still be verified after rotation.
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// Validate checks that the key has an ID, and a secret of at least 32 bytes.
+func (k Key) Validate() error {
+	if k.ID == "" || len(k.Secret) < sha256.Size {
+		return ErrKeyInvalid
+	}
+
+	return nil
+}
+
+// Claims are the contents of a token.
+type Claims struct {
+	KeyID     string    `json:"kid"`
+	Subject   string    `json:"sub"`
+	Purpose   string    `json:"pur"`
+	ExpiresAt time.Time `json:"exp"`
+	Nonce     string    `json:"nonce"`
+
+	// Stamp binds the token to the state of the subject, e.g. a
+	// domain.SecurityStamp. Compare it after verifying to revoke the token
+	// when the state changes.
+	Stamp string `json:"stamp,omitempty"`
+}
+
+// NonceStore records the nonces of used tokens.
+type NonceStore interface {
+	// Use marks the nonce as used until the token expires. It returns false if
+	// the nonce is already used.
+	Use(ctx context.Context, nonce string, expiresAt time.Time) (bool, error)
+}
+
+// Tokens issues and verifies tokens.
+type Tokens struct {
+	// Private.
+	current Key
+	keys    map[string]Key
+
+	// Public.
+	Nonces NonceStore // Optional, tokens are not single-use without it.
+	Now    func() time.Time
+}
+
+// New returns a pointer to Tokens that signs with the current key, and also
+// verifies with the previous keys.
+func New(current Key, previous ...Key) (*Tokens, error) {
+	keys := make(map[string]Key)
+	for _, k := range append([]Key{current}, previous...) {
+		if err := k.Validate(); err != nil {
+			return nil, err
+		}
+
+		keys[k.ID] = k
+	}
+
+	return &Tokens{
+		current: current,
+		keys:    keys,
+		Now:     time.Now,
+	}, nil
+}
+
+// Issue returns a token for the subject and purpose that expires after ttl.
+func (t *Tokens) Issue(subject, purpose string, ttl time.Duration) (string, error) {
+	return t.IssueStamped(subject, purpose, "", ttl)
+}
+
+// IssueStamped is like Issue, but also embeds the stamp in the token.
+func (t *Tokens) IssueStamped(subject, purpose, stamp string, ttl time.Duration) (string, error) {
+	nonce := make([]byte, nonceLen)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return t.sign(Claims{
+		KeyID:     t.current.ID,
+		Subject:   subject,
+		Purpose:   purpose,
+		ExpiresAt: t.Now().Add(ttl).Truncate(time.Second),
+		Nonce:     base64.RawURLEncoding.EncodeToString(nonce),
+		Stamp:     stamp,
+	})
+}
+
+// Verify checks the signature, purpose and expiry of the token, without
+// using it.
+func (t *Tokens) Verify(token, purpose string) (*Claims, error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrTokenInvalid
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrTokenInvalid
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return nil, ErrTokenInvalid
+	}
+
+	var c Claims
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return nil, ErrTokenInvalid
+	}
+
+	key, ok := t.keys[c.KeyID]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	// Constant time comparison.
+	if !hmac.Equal(mac, signature(key, payload)) {
+		return nil, ErrTokenInvalid
+	}
+
+	if c.Purpose != purpose {
+		return nil, ErrPurposeMismatch
+	}
+
+	if !t.Now().Before(c.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+
+	return &c, nil
+}
+
+// Use verifies the token, and marks it as used in the NonceStore, so that it
+// cannot be verified again.
+func (t *Tokens) Use(ctx context.Context, token, purpose string) (*Claims, error) {
+	c, err := t.Verify(token, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Nonces == nil {
+		return c, nil
+	}
+
+	ok, err := t.Nonces.Use(ctx, c.Nonce, c.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, ErrTokenUsed
+	}
+
+	return c, nil
+}
+
+func (t *Tokens) sign(c Claims) (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	sig := base64.RawURLEncoding.EncodeToString(signature(t.keys[c.KeyID], payload))
+
+	return payload + "." + sig, nil
+}
+
+func signature(key Key, payload string) []byte {
+	h := hmac.New(sha256.New, key.Secret)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+// MemoryNonceStore is an in-memory NonceStore for a single instance, and for
+// testing.
+type MemoryNonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+
+	Now func() time.Time
+}
+
+// NewMemoryNonceStore returns a pointer to MemoryNonceStore.
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{
+		nonces: make(map[string]time.Time),
+		Now:    time.Now,
+	}
+}
+
+// Use implements NonceStore. Expired nonces are removed, since their tokens
+// can no longer be verified.
+func (s *MemoryNonceStore) Use(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Now()
+	for n, exp := range s.nonces {
+		if !now.Before(exp) {
+			delete(s.nonces, n)
+		}
+	}
+
+	if _, ok := s.nonces[nonce]; ok {
+		return false, nil
+	}
+
+	s.nonces[nonce] = expiresAt
+
+	return true, nil
+}
@@ -0,0 +1,183 @@
+package tokens_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/tokens"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	key1 = tokens.Key{ID: "1", Secret: bytes.Repeat([]byte("a"), 32)}
+	key2 = tokens.Key{ID: "2", Secret: bytes.Repeat([]byte("b"), 32)}
+)
+
+func TestTokens(t *testing.T) {
+	assert := assert.New(t)
+
+	tk, err := tokens.New(key1)
+	assert.Nil(err)
+
+	token, err := tk.Issue("john.doe@mail.com", "reset_password", time.Hour)
+	assert.Nil(err)
+
+	c, err := tk.Verify(token, "reset_password")
+	assert.Nil(err)
+	assert.Equal("1", c.KeyID)
+	assert.Equal("john.doe@mail.com", c.Subject)
+	assert.Equal("reset_password", c.Purpose)
+	assert.NotEmpty(c.Nonce)
+	assert.WithinDuration(time.Now().Add(time.Hour), c.ExpiresAt, time.Second)
+
+	_, err = tk.Verify(token, "verify_email")
+	assert.ErrorIs(err, tokens.ErrPurposeMismatch)
+
+	// Tampered signature.
+	_, err = tk.Verify(token[:len(token)-2]+"AA", "reset_password")
+	assert.ErrorIs(err, tokens.ErrTokenInvalid)
+
+	// Tampered payload.
+	payload, sig, _ := strings.Cut(token, ".")
+	other, err := tk.Issue("jane.doe@mail.com", "reset_password", time.Hour)
+	assert.Nil(err)
+	otherPayload, _, _ := strings.Cut(other, ".")
+	_, err = tk.Verify(otherPayload+"."+sig, "reset_password")
+	assert.ErrorIs(err, tokens.ErrTokenInvalid)
+	_, err = tk.Verify(payload, "reset_password")
+	assert.ErrorIs(err, tokens.ErrTokenInvalid)
+	_, err = tk.Verify("", "reset_password")
+	assert.ErrorIs(err, tokens.ErrTokenInvalid)
+}
+
+func TestTokensStamp(t *testing.T) {
+	assert := assert.New(t)
+
+	tk, err := tokens.New(key1)
+	assert.Nil(err)
+
+	token, err := tk.IssueStamped("john.doe@mail.com", "reset_password", "stamp", time.Hour)
+	assert.Nil(err)
+
+	c, err := tk.Verify(token, "reset_password")
+	assert.Nil(err)
+	assert.Equal("stamp", c.Stamp)
+
+	token, err = tk.Issue("john.doe@mail.com", "reset_password", time.Hour)
+	assert.Nil(err)
+
+	c, err = tk.Verify(token, "reset_password")
+	assert.Nil(err)
+	assert.Empty(c.Stamp)
+}
+
+func TestTokensExpired(t *testing.T) {
+	assert := assert.New(t)
+
+	tk, err := tokens.New(key1)
+	assert.Nil(err)
+
+	token, err := tk.Issue("john.doe@mail.com", "reset_password", time.Hour)
+	assert.Nil(err)
+
+	tk.Now = func() time.Time {
+		return time.Now().Add(time.Hour + time.Second)
+	}
+	_, err = tk.Verify(token, "reset_password")
+	assert.ErrorIs(err, tokens.ErrTokenExpired)
+}
+
+func TestTokensKeyRotation(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := tokens.New(tokens.Key{ID: "short", Secret: []byte("secret")})
+	assert.ErrorIs(err, tokens.ErrKeyInvalid)
+
+	old, err := tokens.New(key1)
+	assert.Nil(err)
+
+	token, err := old.Issue("john.doe@mail.com", "reset_password", time.Hour)
+	assert.Nil(err)
+
+	rotated, err := tokens.New(key2, key1)
+	assert.Nil(err)
+
+	_, err = rotated.Verify(token, "reset_password")
+	assert.Nil(err)
+
+	newToken, err := rotated.Issue("john.doe@mail.com", "reset_password", time.Hour)
+	assert.Nil(err)
+
+	c, err := rotated.Verify(newToken, "reset_password")
+	assert.Nil(err)
+	assert.Equal("2", c.KeyID)
+
+	// The previous key is retired.
+	retired, err := tokens.New(key2)
+	assert.Nil(err)
+
+	_, err = retired.Verify(token, "reset_password")
+	assert.ErrorIs(err, tokens.ErrKeyNotFound)
+
+	// Keys with different IDs do not verify each other's tokens.
+	_, err = old.Verify(newToken, "reset_password")
+	assert.ErrorIs(err, tokens.ErrKeyNotFound)
+}
+
+func TestTokensUse(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	tk, err := tokens.New(key1)
+	assert.Nil(err)
+
+	token, err := tk.Issue("john.doe@mail.com", "reset_password", time.Hour)
+	assert.Nil(err)
+
+	// Without a NonceStore, tokens can be reused until they expire.
+	_, err = tk.Use(ctx, token, "reset_password")
+	assert.Nil(err)
+	_, err = tk.Use(ctx, token, "reset_password")
+	assert.Nil(err)
+
+	tk.Nonces = tokens.NewMemoryNonceStore()
+	_, err = tk.Use(ctx, token, "reset_password")
+	assert.Nil(err)
+	_, err = tk.Use(ctx, token, "reset_password")
+	assert.ErrorIs(err, tokens.ErrTokenUsed)
+
+	// Verify does not use the token.
+	other, err := tk.Issue("john.doe@mail.com", "reset_password", time.Hour)
+	assert.Nil(err)
+	_, err = tk.Verify(other, "reset_password")
+	assert.Nil(err)
+	_, err = tk.Use(ctx, other, "reset_password")
+	assert.Nil(err)
+}
+
+func TestMemoryNonceStore(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	store := tokens.NewMemoryNonceStore()
+
+	now := time.Now()
+	ok, err := store.Use(ctx, "nonce", now.Add(time.Minute))
+	assert.Nil(err)
+	assert.True(ok)
+
+	ok, err = store.Use(ctx, "nonce", now.Add(time.Minute))
+	assert.Nil(err)
+	assert.False(ok)
+
+	// Expired nonces are removed.
+	store.Now = func() time.Time {
+		return now.Add(time.Minute)
+	}
+	ok, err = store.Use(ctx, "nonce", now.Add(2*time.Minute))
+	assert.Nil(err)
+	assert.True(ok)
+}
@@ -0,0 +1,86 @@
+package webauthn
+
+import (
+	"encoding/binary"
+)
+
+// Authenticator data flags.
+const (
+	flagUserPresent       = 0x01
+	flagUserVerified      = 0x04
+	flagBackupEligible    = 0x08
+	flagBackedUp          = 0x10
+	flagAttestedData      = 0x40
+	flagExtensionIncluded = 0x80
+)
+
+// authenticatorData is the parsed authenticator data, see
+// https://www.w3.org/TR/webauthn-2/#sctn-authenticator-data.
+type authenticatorData struct {
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+
+	// Only with flagAttestedData.
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+func (d authenticatorData) has(flag byte) bool {
+	return d.flags&flag == flag
+}
+
+func parseAuthenticatorData(b []byte) (authenticatorData, error) {
+	if len(b) < 37 {
+		return authenticatorData{}, ErrAuthenticatorDataInvalid
+	}
+
+	d := authenticatorData{
+		rpIDHash:  b[:32],
+		flags:     b[32],
+		signCount: binary.BigEndian.Uint32(b[33:37]),
+	}
+	b = b[37:]
+
+	if d.has(flagAttestedData) {
+		if len(b) < 18 {
+			return authenticatorData{}, ErrAuthenticatorDataInvalid
+		}
+
+		d.aaguid = b[:16]
+		n := int(binary.BigEndian.Uint16(b[16:18]))
+		b = b[18:]
+		if n == 0 || n > 1023 || len(b) < n {
+			return authenticatorData{}, ErrAuthenticatorDataInvalid
+		}
+
+		d.credentialID = b[:n]
+		b = b[n:]
+
+		// The public key is followed by the extensions, so it is decoded to
+		// find its length.
+		_, rest, err := decodeCBOR(b)
+		if err != nil {
+			return authenticatorData{}, ErrAuthenticatorDataInvalid
+		}
+
+		d.publicKey = b[:len(b)-len(rest)]
+		b = rest
+	}
+
+	if d.has(flagExtensionIncluded) {
+		_, rest, err := decodeCBOR(b)
+		if err != nil {
+			return authenticatorData{}, ErrAuthenticatorDataInvalid
+		}
+
+		b = rest
+	}
+
+	if len(b) != 0 {
+		return authenticatorData{}, ErrAuthenticatorDataInvalid
+	}
+
+	return d, nil
+}
@@ -0,0 +1,141 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// maxDepth limits the nesting of arrays and maps in decodeCBOR.
+const maxDepth = 16
+
+// decodeCBOR decodes the first CBOR item in b, and returns the remaining
+// bytes. Only the subset used by WebAuthn is supported: definite lengths,
+// integers as int64, byte strings as []byte, text strings as string, arrays
+// as []any, maps as map[any]any with integer or text keys, and the simple
+// values false, true and null. Tags are skipped.
+func decodeCBOR(b []byte) (any, []byte, error) {
+	return decodeItem(b, 0)
+}
+
+func decodeItem(b []byte, depth int) (any, []byte, error) {
+	if depth > maxDepth || len(b) == 0 {
+		return nil, nil, ErrCBORInvalid
+	}
+
+	major, info := b[0]>>5, b[0]&0x1f
+	b = b[1:]
+
+	// Simple values.
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, b, nil
+		case 21:
+			return true, b, nil
+		case 22:
+			return nil, b, nil
+		default:
+			return nil, nil, ErrCBORInvalid
+		}
+	}
+
+	n, b, err := decodeArgument(info, b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case 0:
+		if n > math.MaxInt64 {
+			return nil, nil, ErrCBORInvalid
+		}
+
+		return int64(n), b, nil
+	case 1:
+		if n > math.MaxInt64 {
+			return nil, nil, ErrCBORInvalid
+		}
+
+		return -1 - int64(n), b, nil
+	case 2, 3:
+		if n > uint64(len(b)) {
+			return nil, nil, ErrCBORInvalid
+		}
+
+		if major == 2 {
+			return append([]byte(nil), b[:n]...), b[n:], nil
+		}
+
+		return string(b[:n]), b[n:], nil
+	case 4:
+		// Each item takes at least a byte.
+		if n > uint64(len(b)) {
+			return nil, nil, ErrCBORInvalid
+		}
+
+		items := make([]any, n)
+		for i := range items {
+			items[i], b, err = decodeItem(b, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		return items, b, nil
+	case 5:
+		if n > uint64(len(b))/2 {
+			return nil, nil, ErrCBORInvalid
+		}
+
+		m := make(map[any]any, n)
+		for i := uint64(0); i < n; i++ {
+			var k, v any
+			k, b, err = decodeItem(b, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, nil, ErrCBORInvalid
+			}
+
+			if _, ok := m[k]; ok {
+				return nil, nil, ErrCBORInvalid
+			}
+
+			v, b, err = decodeItem(b, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			m[k] = v
+		}
+
+		return m, b, nil
+	case 6:
+		return decodeItem(b, depth+1)
+	}
+
+	return nil, nil, ErrCBORInvalid
+}
+
+// decodeArgument decodes the length or value that follows the initial byte.
+// Indefinite lengths are not supported.
+func decodeArgument(info byte, b []byte) (uint64, []byte, error) {
+	switch {
+	case info < 24:
+		return uint64(info), b, nil
+	case info == 24 && len(b) >= 1:
+		return uint64(b[0]), b[1:], nil
+	case info == 25 && len(b) >= 2:
+		return uint64(binary.BigEndian.Uint16(b)), b[2:], nil
+	case info == 26 && len(b) >= 4:
+		return uint64(binary.BigEndian.Uint32(b)), b[4:], nil
+	case info == 27 && len(b) >= 8:
+		return binary.BigEndian.Uint64(b), b[8:], nil
+	}
+
+	return 0, nil, ErrCBORInvalid
+}
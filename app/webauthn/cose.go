@@ -0,0 +1,112 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"math/big"
+)
+
+// COSE algorithms, see https://www.iana.org/assignments/cose.
+const (
+	AlgES256 = -7
+	AlgRS256 = -257
+)
+
+// COSE key parameters.
+const (
+	coseKty    = 1
+	coseAlg    = 3
+	coseCrv    = -1
+	coseX      = -2
+	coseY      = -3
+	coseRSAN   = -1
+	coseRSAE   = -2
+	coseKtyEC2 = 2
+	coseKtyRSA = 3
+	coseP256   = 1
+)
+
+// minRSABits is the minimum size of RS256 keys.
+const minRSABits = 2048
+
+// parsePublicKey parses a COSE_Key, and returns the algorithm and public key.
+func parsePublicKey(b []byte) (int, crypto.PublicKey, error) {
+	v, rest, err := decodeCBOR(b)
+	if err != nil || len(rest) != 0 {
+		return 0, nil, ErrPublicKeyInvalid
+	}
+
+	return publicKeyFromMap(v)
+}
+
+func publicKeyFromMap(v any) (int, crypto.PublicKey, error) {
+	m, ok := v.(map[any]any)
+	if !ok {
+		return 0, nil, ErrPublicKeyInvalid
+	}
+
+	kty, _ := m[int64(coseKty)].(int64)
+	alg, _ := m[int64(coseAlg)].(int64)
+
+	switch {
+	case kty == coseKtyEC2 && alg == AlgES256:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		y, _ := m[int64(coseY)].([]byte)
+		if crv != coseP256 || len(x) != 32 || len(y) != 32 {
+			return 0, nil, ErrPublicKeyInvalid
+		}
+
+		pub := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return 0, nil, ErrPublicKeyInvalid
+		}
+
+		return AlgES256, pub, nil
+	case kty == coseKtyRSA && alg == AlgRS256:
+		n, _ := m[int64(coseRSAN)].([]byte)
+		e, _ := m[int64(coseRSAE)].([]byte)
+		if len(e) == 0 || len(e) > 4 {
+			return 0, nil, ErrPublicKeyInvalid
+		}
+
+		pub := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if pub.N.BitLen() < minRSABits || pub.E < 3 {
+			return 0, nil, ErrPublicKeyInvalid
+		}
+
+		return AlgRS256, pub, nil
+	case alg != AlgES256 && alg != AlgRS256:
+		return 0, nil, ErrAlgorithmUnsupported
+	}
+
+	return 0, nil, ErrPublicKeyInvalid
+}
+
+// verifySignature checks the signature of the message with the public key.
+func verifySignature(alg int, pub crypto.PublicKey, msg, sig []byte) error {
+	digest := sha256.Sum256(msg)
+
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		if alg == AlgES256 && ecdsa.VerifyASN1(k, digest[:], sig) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		if alg == AlgRS256 && rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil {
+			return nil
+		}
+	}
+
+	return ErrSignatureInvalid
+}
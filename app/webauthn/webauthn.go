@@ -0,0 +1,491 @@
+// Package webauthn verifies passkey registrations and logins for a relying
+// party, see https://www.w3.org/TR/webauthn-2. It builds the options passed
+// to navigator.credentials.create and navigator.credentials.get, and
+// verifies the responses. ES256 and RS256 keys, and the "none" and self
+// "packed" attestation formats are supported.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const challengeLen = 32
+
+// DefaultTimeout is the time the User has to complete a ceremony.
+var DefaultTimeout = 5 * time.Minute
+
+// Requirements for user verification, e.g. with a PIN or biometrics.
+const (
+	UserVerificationRequired    = "required"
+	UserVerificationPreferred   = "preferred"
+	UserVerificationDiscouraged = "discouraged"
+)
+
+// Client data types.
+const (
+	typeCreate = "webauthn.create"
+	typeGet    = "webauthn.get"
+)
+
+const credentialType = "public-key"
+
+// WebAuthn errors.
+var (
+	ErrRelyingPartyInvalid      = errors.New("webauthn: invalid relying party")
+	ErrUserInvalid              = errors.New("webauthn: invalid user")
+	ErrCBORInvalid              = errors.New("webauthn: invalid cbor")
+	ErrPublicKeyInvalid         = errors.New("webauthn: invalid public key")
+	ErrAlgorithmUnsupported     = errors.New("webauthn: algorithm unsupported")
+	ErrAttestationInvalid       = errors.New("webauthn: invalid attestation")
+	ErrAttestationUnsupported   = errors.New("webauthn: attestation format unsupported")
+	ErrAuthenticatorDataInvalid = errors.New("webauthn: invalid authenticator data")
+	ErrClientDataInvalid        = errors.New("webauthn: invalid client data")
+	ErrCredentialInvalid        = errors.New("webauthn: invalid credential")
+	ErrCredentialMismatch       = errors.New("webauthn: credential mismatch")
+	ErrChallengeMismatch        = errors.New("webauthn: challenge mismatch")
+	ErrOriginMismatch           = errors.New("webauthn: origin mismatch")
+	ErrRelyingPartyMismatch     = errors.New("webauthn: relying party mismatch")
+	ErrUserNotPresent           = errors.New("webauthn: user not present")
+	ErrUserNotVerified          = errors.New("webauthn: user not verified")
+	ErrSignatureInvalid         = errors.New("webauthn: invalid signature")
+
+	// ErrSignCountInvalid is returned by VerifyAssertion when the signature
+	// counter did not increase, which means that the authenticator may be
+	// cloned. The signature is valid.
+	ErrSignCountInvalid = errors.New("webauthn: sign count did not increase")
+)
+
+// Bytes is encoded as unpadded base64url in JSON, like the WebAuthn JSON
+// serialization.
+type Bytes []byte
+
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *Bytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	v, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+
+	*b = v
+	return nil
+}
+
+// User is the account a passkey is registered for. The ID is the user handle,
+// and should not contain personal information.
+type User struct {
+	ID          Bytes  `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// Credential is a registered passkey.
+type Credential struct {
+	ID        Bytes
+	PublicKey Bytes // COSE_Key.
+	Algorithm int
+	SignCount uint32
+	AAGUID    Bytes
+
+	// Whether the passkey is synced, e.g. to the cloud.
+	BackupEligible bool
+	BackedUp       bool
+
+	Transports []string
+}
+
+// CredentialDescriptor identifies a passkey in the options.
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         Bytes    `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions are passed to navigator.credentials.create.
+type CreationOptions struct {
+	Challenge              Bytes                  `json:"challenge"`
+	RP                     RelyingPartyEntity     `json:"rp"`
+	User                   User                   `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions are passed to navigator.credentials.get.
+type RequestOptions struct {
+	Challenge        Bytes                  `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+type AttestationResponse struct {
+	ClientDataJSON    Bytes    `json:"clientDataJSON"`
+	AttestationObject Bytes    `json:"attestationObject"`
+	Transports        []string `json:"transports,omitempty"`
+}
+
+// CredentialCreation is the response of navigator.credentials.create.
+type CredentialCreation struct {
+	ID       string              `json:"id"`
+	RawID    Bytes               `json:"rawId"`
+	Type     string              `json:"type"`
+	Response AttestationResponse `json:"response"`
+}
+
+type AssertionResponse struct {
+	ClientDataJSON    Bytes `json:"clientDataJSON"`
+	AuthenticatorData Bytes `json:"authenticatorData"`
+	Signature         Bytes `json:"signature"`
+	UserHandle        Bytes `json:"userHandle,omitempty"`
+}
+
+// CredentialAssertion is the response of navigator.credentials.get.
+type CredentialAssertion struct {
+	ID       string            `json:"id"`
+	RawID    Bytes             `json:"rawId"`
+	Type     string            `json:"type"`
+	Response AssertionResponse `json:"response"`
+}
+
+// UserVerified returns true if the authenticator verified the user, e.g. with
+// a PIN or biometrics, and not only their presence. Only trust it after
+// VerifyAssertion.
+func (a CredentialAssertion) UserVerified() bool {
+	data, err := parseAuthenticatorData(a.Response.AuthenticatorData)
+	return err == nil && data.has(flagUserVerified)
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// RelyingParty verifies the passkeys for a domain.
+type RelyingParty struct {
+	// Private.
+	id      string
+	name    string
+	origins map[string]bool
+
+	// Public.
+	Timeout          time.Duration
+	UserVerification string
+}
+
+// New returns a pointer to RelyingParty for the domain, e.g. example.com,
+// that accepts responses from the origins, e.g. https://example.com.
+func New(id, name string, origins ...string) (*RelyingParty, error) {
+	if id == "" || name == "" || len(origins) == 0 {
+		return nil, ErrRelyingPartyInvalid
+	}
+
+	m := make(map[string]bool)
+	for _, o := range origins {
+		m[o] = true
+	}
+
+	return &RelyingParty{
+		id:               id,
+		name:             name,
+		origins:          m,
+		Timeout:          DefaultTimeout,
+		UserVerification: UserVerificationPreferred,
+	}, nil
+}
+
+// ID returns the domain of the relying party.
+func (rp *RelyingParty) ID() string {
+	return rp.id
+}
+
+// CreationOptions returns the options to register a passkey for the User.
+// The passkeys in exclude are already registered, and are not registered
+// again on the same authenticator. The challenge needs to be kept until the
+// response is verified with VerifyCreation.
+func (rp *RelyingParty) CreationOptions(user User, exclude []Credential) (*CreationOptions, error) {
+	if len(user.ID) == 0 || len(user.ID) > 64 || user.Name == "" {
+		return nil, ErrUserInvalid
+	}
+
+	if user.DisplayName == "" {
+		user.DisplayName = user.Name
+	}
+
+	challenge, err := newChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreationOptions{
+		Challenge: challenge,
+		RP:        RelyingPartyEntity{ID: rp.id, Name: rp.name},
+		User:      user,
+		PubKeyCredParams: []CredentialParameter{
+			{Type: credentialType, Alg: AlgES256},
+			{Type: credentialType, Alg: AlgRS256},
+		},
+		Timeout:            rp.Timeout.Milliseconds(),
+		ExcludeCredentials: descriptors(exclude),
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "required",
+			UserVerification: rp.UserVerification,
+		},
+		Attestation: "none",
+	}, nil
+}
+
+// RequestOptions returns the options to login with a passkey. Without allow,
+// the User picks any passkey for the relying party. The challenge needs to be
+// kept until the response is verified with VerifyAssertion.
+func (rp *RelyingParty) RequestOptions(allow []Credential) (*RequestOptions, error) {
+	challenge, err := newChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          rp.Timeout.Milliseconds(),
+		RPID:             rp.id,
+		AllowCredentials: descriptors(allow),
+		UserVerification: rp.UserVerification,
+	}, nil
+}
+
+// VerifyCreation verifies the registration response for the challenge, and
+// returns the passkey to store.
+func (rp *RelyingParty) VerifyCreation(c CredentialCreation, challenge []byte) (*Credential, error) {
+	if err := checkCredentialID(c.Type, c.ID, c.RawID); err != nil {
+		return nil, err
+	}
+
+	if err := rp.verifyClientData(c.Response.ClientDataJSON, typeCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	v, rest, err := decodeCBOR(c.Response.AttestationObject)
+	if err != nil || len(rest) != 0 {
+		return nil, ErrAttestationInvalid
+	}
+
+	obj, _ := v.(map[any]any)
+	format, _ := obj["fmt"].(string)
+	stmt, _ := obj["attStmt"].(map[any]any)
+	raw, _ := obj["authData"].([]byte)
+	if format == "" || stmt == nil || raw == nil {
+		return nil, ErrAttestationInvalid
+	}
+
+	data, err := rp.verifyAuthenticatorData(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if !data.has(flagAttestedData) {
+		return nil, ErrAuthenticatorDataInvalid
+	}
+
+	if !bytes.Equal(data.credentialID, c.RawID) {
+		return nil, ErrCredentialMismatch
+	}
+
+	alg, pub, err := parsePublicKey(data.publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "none":
+		if len(stmt) != 0 {
+			return nil, ErrAttestationInvalid
+		}
+	case "packed":
+		// Only self attestation, which is signed with the credential key.
+		if _, ok := stmt["x5c"]; ok {
+			return nil, ErrAttestationUnsupported
+		}
+
+		if stmtAlg, _ := stmt["alg"].(int64); stmtAlg != int64(alg) {
+			return nil, ErrAttestationInvalid
+		}
+
+		sig, _ := stmt["sig"].([]byte)
+		if err := verifySignature(alg, pub, signedData(raw, c.Response.ClientDataJSON), sig); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrAttestationUnsupported
+	}
+
+	return &Credential{
+		ID:             append(Bytes(nil), data.credentialID...),
+		PublicKey:      append(Bytes(nil), data.publicKey...),
+		Algorithm:      alg,
+		SignCount:      data.signCount,
+		AAGUID:         append(Bytes(nil), data.aaguid...),
+		BackupEligible: data.has(flagBackupEligible),
+		BackedUp:       data.has(flagBackedUp),
+		Transports:     c.Response.Transports,
+	}, nil
+}
+
+// VerifyAssertion verifies the login response for the challenge with the
+// stored passkey, and returns the passkey with the updated sign count and
+// backup state to store.
+func (rp *RelyingParty) VerifyAssertion(a CredentialAssertion, challenge []byte, cred Credential) (*Credential, error) {
+	if err := checkCredentialID(a.Type, a.ID, a.RawID); err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(a.RawID, cred.ID) {
+		return nil, ErrCredentialMismatch
+	}
+
+	if err := rp.verifyClientData(a.Response.ClientDataJSON, typeGet, challenge); err != nil {
+		return nil, err
+	}
+
+	raw := a.Response.AuthenticatorData
+	data, err := rp.verifyAuthenticatorData(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	alg, pub, err := parsePublicKey(cred.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifySignature(alg, pub, signedData(raw, a.Response.ClientDataJSON), a.Response.Signature); err != nil {
+		return nil, err
+	}
+
+	// Authenticators without a counter always return zero.
+	if (data.signCount != 0 || cred.SignCount != 0) && data.signCount <= cred.SignCount {
+		return nil, ErrSignCountInvalid
+	}
+
+	cred.SignCount = data.signCount
+	cred.BackedUp = data.has(flagBackedUp)
+	return &cred, nil
+}
+
+func (rp *RelyingParty) verifyClientData(b []byte, typ string, challenge []byte) error {
+	var c clientData
+	if err := json.Unmarshal(b, &c); err != nil {
+		return ErrClientDataInvalid
+	}
+
+	if c.Type != typ {
+		return ErrClientDataInvalid
+	}
+
+	got, err := base64.RawURLEncoding.DecodeString(c.Challenge)
+	if err != nil {
+		return ErrClientDataInvalid
+	}
+
+	// Constant time comparison.
+	if len(challenge) == 0 || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return ErrChallengeMismatch
+	}
+
+	if !rp.origins[c.Origin] || c.CrossOrigin {
+		return ErrOriginMismatch
+	}
+
+	return nil
+}
+
+func (rp *RelyingParty) verifyAuthenticatorData(b []byte) (authenticatorData, error) {
+	data, err := parseAuthenticatorData(b)
+	if err != nil {
+		return authenticatorData{}, err
+	}
+
+	hash := sha256.Sum256([]byte(rp.id))
+	if !bytes.Equal(data.rpIDHash, hash[:]) {
+		return authenticatorData{}, ErrRelyingPartyMismatch
+	}
+
+	if !data.has(flagUserPresent) {
+		return authenticatorData{}, ErrUserNotPresent
+	}
+
+	if rp.UserVerification == UserVerificationRequired && !data.has(flagUserVerified) {
+		return authenticatorData{}, ErrUserNotVerified
+	}
+
+	return data, nil
+}
+
+func checkCredentialID(typ, id string, rawID []byte) error {
+	if typ != credentialType || len(rawID) == 0 || id != base64.RawURLEncoding.EncodeToString(rawID) {
+		return ErrCredentialInvalid
+	}
+
+	return nil
+}
+
+// signedData returns the data signed by the authenticator.
+func signedData(authData, clientDataJSON []byte) []byte {
+	hash := sha256.Sum256(clientDataJSON)
+	return append(append([]byte(nil), authData...), hash[:]...)
+}
+
+func descriptors(creds []Credential) []CredentialDescriptor {
+	res := make([]CredentialDescriptor, len(creds))
+	for i, c := range creds {
+		res[i] = CredentialDescriptor{
+			Type:       credentialType,
+			ID:         c.ID,
+			Transports: c.Transports,
+		}
+	}
+
+	return res
+}
+
+func newChallenge() (Bytes, error) {
+	b := make([]byte, challengeLen)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
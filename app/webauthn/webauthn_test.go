@@ -0,0 +1,319 @@
+package webauthn_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/webauthn"
+	"github.com/alextanhongpin/go-service-oriented-package/app/webauthn/webauthntest"
+	"github.com/stretchr/testify/assert"
+)
+
+const origin = "https://example.com"
+
+var user = webauthn.User{ID: []byte("user-1"), Name: "john.doe@mail.com"}
+
+func newRelyingParty(t *testing.T) *webauthn.RelyingParty {
+	t.Helper()
+
+	rp, err := webauthn.New("example.com", "Example", origin)
+	assert.Nil(t, err)
+
+	return rp
+}
+
+func newAuthenticator(t *testing.T, alg int) *webauthntest.Authenticator {
+	t.Helper()
+
+	a, err := webauthntest.New(alg, origin)
+	assert.Nil(t, err)
+
+	return a
+}
+
+// register creates a passkey on the authenticator, and verifies it.
+func register(t *testing.T, rp *webauthn.RelyingParty, a *webauthntest.Authenticator) *webauthn.Credential {
+	t.Helper()
+
+	opts, err := rp.CreationOptions(user, nil)
+	assert.Nil(t, err)
+
+	res, err := a.Create(*opts)
+	assert.Nil(t, err)
+
+	cred, err := rp.VerifyCreation(res, opts.Challenge)
+	assert.Nil(t, err)
+
+	return cred
+}
+
+func login(t *testing.T, rp *webauthn.RelyingParty, a *webauthntest.Authenticator, cred webauthn.Credential) (*webauthn.Credential, error) {
+	t.Helper()
+
+	opts, err := rp.RequestOptions([]webauthn.Credential{cred})
+	assert.Nil(t, err)
+
+	res, err := a.Get(*opts)
+	assert.Nil(t, err)
+
+	return rp.VerifyAssertion(res, opts.Challenge, cred)
+}
+
+func TestNew(t *testing.T) {
+	_, err := webauthn.New("example.com", "Example")
+	assert.ErrorIs(t, err, webauthn.ErrRelyingPartyInvalid)
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	for name, alg := range map[string]int{"es256": webauthn.AlgES256, "rs256": webauthn.AlgRS256} {
+		alg := alg
+
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			rp := newRelyingParty(t)
+			a := newAuthenticator(t, alg)
+			cred := register(t, rp, a)
+			assert.Equal(webauthn.Bytes(a.CredentialID()), cred.ID)
+			assert.Equal(alg, cred.Algorithm)
+			assert.Equal(uint32(0), cred.SignCount)
+			assert.True(cred.BackupEligible)
+			assert.Equal([]string{"internal"}, cred.Transports)
+
+			for i := 1; i <= 2; i++ {
+				var err error
+				cred, err = login(t, rp, a, *cred)
+				assert.Nil(err)
+				assert.Equal(uint32(i), cred.SignCount)
+			}
+		})
+	}
+}
+
+func TestVerifyCreation(t *testing.T) {
+	rp := newRelyingParty(t)
+
+	create := func(t *testing.T, a *webauthntest.Authenticator) (webauthn.CredentialCreation, []byte) {
+		t.Helper()
+
+		opts, err := rp.CreationOptions(user, nil)
+		assert.Nil(t, err)
+
+		res, err := a.Create(*opts)
+		assert.Nil(t, err)
+
+		return res, opts.Challenge
+	}
+
+	t.Run("packed self attestation", func(t *testing.T) {
+		a := newAuthenticator(t, webauthn.AlgES256)
+		a.Format = "packed"
+
+		res, challenge := create(t, a)
+		_, err := rp.VerifyCreation(res, challenge)
+		assert.Nil(t, err)
+	})
+
+	t.Run("unsupported attestation", func(t *testing.T) {
+		a := newAuthenticator(t, webauthn.AlgES256)
+		a.Format = "tpm"
+
+		res, challenge := create(t, a)
+		_, err := rp.VerifyCreation(res, challenge)
+		assert.ErrorIs(t, err, webauthn.ErrAttestationUnsupported)
+	})
+
+	t.Run("challenge mismatch", func(t *testing.T) {
+		res, _ := create(t, newAuthenticator(t, webauthn.AlgES256))
+		_, err := rp.VerifyCreation(res, []byte("challenge"))
+		assert.ErrorIs(t, err, webauthn.ErrChallengeMismatch)
+	})
+
+	t.Run("origin mismatch", func(t *testing.T) {
+		a := newAuthenticator(t, webauthn.AlgES256)
+		a.Origin = "https://evil.example.com"
+
+		res, challenge := create(t, a)
+		_, err := rp.VerifyCreation(res, challenge)
+		assert.ErrorIs(t, err, webauthn.ErrOriginMismatch)
+	})
+
+	t.Run("relying party mismatch", func(t *testing.T) {
+		opts, err := rp.CreationOptions(user, nil)
+		assert.Nil(t, err)
+		opts.RP.ID = "evil.com"
+
+		res, err := newAuthenticator(t, webauthn.AlgES256).Create(*opts)
+		assert.Nil(t, err)
+
+		_, err = rp.VerifyCreation(res, opts.Challenge)
+		assert.ErrorIs(t, err, webauthn.ErrRelyingPartyMismatch)
+	})
+
+	t.Run("user verification required", func(t *testing.T) {
+		rp := newRelyingParty(t)
+		rp.UserVerification = webauthn.UserVerificationRequired
+
+		a := newAuthenticator(t, webauthn.AlgES256)
+		a.UserVerified = false
+
+		opts, err := rp.CreationOptions(user, nil)
+		assert.Nil(t, err)
+
+		res, err := a.Create(*opts)
+		assert.Nil(t, err)
+
+		_, err = rp.VerifyCreation(res, opts.Challenge)
+		assert.ErrorIs(t, err, webauthn.ErrUserNotVerified)
+	})
+
+	t.Run("credential mismatch", func(t *testing.T) {
+		res, challenge := create(t, newAuthenticator(t, webauthn.AlgES256))
+		res.RawID = []byte("other")
+		res.ID = "b3RoZXI"
+
+		_, err := rp.VerifyCreation(res, challenge)
+		assert.ErrorIs(t, err, webauthn.ErrCredentialMismatch)
+	})
+
+	t.Run("invalid attestation object", func(t *testing.T) {
+		res, challenge := create(t, newAuthenticator(t, webauthn.AlgES256))
+		deep := make([]byte, 20)
+		for i := range deep {
+			deep[i] = 0x81
+		}
+
+		tests := map[string][]byte{
+			"truncated":         res.Response.AttestationObject[:10],
+			"trailing bytes":    append(res.Response.AttestationObject, 0x00),
+			"huge array":        {0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			"indefinite length": {0xbf, 0x63, 'f', 'm', 't', 0x64, 'n', 'o', 'n', 'e', 0xff},
+			"float":             {0xf9, 0x3c, 0x00},
+			"bytes key":         {0xa1, 0x41, 0x01, 0x01},
+			"duplicate key":     {0xa2, 0x01, 0x01, 0x01, 0x02},
+			"too deep":          append(deep, 0x01),
+		}
+
+		for name, obj := range tests {
+			res := res
+			res.Response.AttestationObject = obj
+
+			_, err := rp.VerifyCreation(res, challenge)
+			assert.ErrorIs(t, err, webauthn.ErrAttestationInvalid, name)
+		}
+	})
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	rp := newRelyingParty(t)
+
+	t.Run("cloned authenticator", func(t *testing.T) {
+		assert := assert.New(t)
+
+		a := newAuthenticator(t, webauthn.AlgES256)
+		cred := register(t, rp, a)
+		clone := *a
+
+		cred, err := login(t, rp, a, *cred)
+		assert.Nil(err)
+
+		_, err = login(t, rp, &clone, *cred)
+		assert.ErrorIs(err, webauthn.ErrSignCountInvalid)
+	})
+
+	t.Run("counterless", func(t *testing.T) {
+		a := newAuthenticator(t, webauthn.AlgES256)
+		a.Counterless = true
+		cred := register(t, rp, a)
+
+		for i := 0; i < 2; i++ {
+			_, err := login(t, rp, a, *cred)
+			assert.Nil(t, err)
+		}
+	})
+
+	t.Run("signature invalid", func(t *testing.T) {
+		a := newAuthenticator(t, webauthn.AlgES256)
+		cred := register(t, rp, a)
+
+		// Another key for the same credential ID.
+		other := register(t, rp, newAuthenticator(t, webauthn.AlgES256))
+		cred.PublicKey = other.PublicKey
+
+		_, err := login(t, rp, a, *cred)
+		assert.ErrorIs(t, err, webauthn.ErrSignatureInvalid)
+	})
+
+	t.Run("challenge reused", func(t *testing.T) {
+		a := newAuthenticator(t, webauthn.AlgES256)
+		cred := register(t, rp, a)
+
+		opts, err := rp.RequestOptions(nil)
+		assert.Nil(t, err)
+		assert.Empty(t, opts.AllowCredentials)
+
+		res, err := a.Get(*opts)
+		assert.Nil(t, err)
+
+		other, err := rp.RequestOptions(nil)
+		assert.Nil(t, err)
+
+		_, err = rp.VerifyAssertion(res, other.Challenge, *cred)
+		assert.ErrorIs(t, err, webauthn.ErrChallengeMismatch)
+	})
+
+	t.Run("credential mismatch", func(t *testing.T) {
+		a := newAuthenticator(t, webauthn.AlgES256)
+		register(t, rp, a)
+		other := register(t, rp, newAuthenticator(t, webauthn.AlgES256))
+
+		opts, err := rp.RequestOptions(nil)
+		assert.Nil(t, err)
+
+		res, err := a.Get(*opts)
+		assert.Nil(t, err)
+
+		_, err = rp.VerifyAssertion(res, opts.Challenge, *other)
+		assert.ErrorIs(t, err, webauthn.ErrCredentialMismatch)
+	})
+
+	t.Run("user verified", func(t *testing.T) {
+		assert := assert.New(t)
+
+		a := newAuthenticator(t, webauthn.AlgES256)
+		register(t, rp, a)
+
+		opts, err := rp.RequestOptions(nil)
+		assert.Nil(err)
+
+		res, err := a.Get(*opts)
+		assert.Nil(err)
+		assert.True(res.UserVerified())
+
+		a.UserVerified = false
+		res, err = a.Get(*opts)
+		assert.Nil(err)
+		assert.False(res.UserVerified())
+	})
+}
+
+func TestOptionsJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	rp := newRelyingParty(t)
+	opts, err := rp.CreationOptions(user, []webauthn.Credential{{ID: []byte{0xfb, 0xff}}})
+	assert.Nil(err)
+
+	b, err := json.Marshal(opts)
+	assert.Nil(err)
+
+	var m map[string]any
+	assert.Nil(json.Unmarshal(b, &m))
+	assert.Equal("dXNlci0x", m["user"].(map[string]any)["id"])
+	assert.Equal("-_8", m["excludeCredentials"].([]any)[0].(map[string]any)["id"])
+	assert.Len(m["challenge"], 43)
+
+	var got webauthn.CreationOptions
+	assert.Nil(json.Unmarshal(b, &got))
+	assert.Equal(*opts, got)
+}
@@ -0,0 +1,226 @@
+// Package webauthntest provides a software authenticator, to test passkey
+// flows without hardware.
+package webauthntest
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/alextanhongpin/go-service-oriented-package/app/webauthn"
+)
+
+// ErrCredentialNotAllowed is returned by Authenticator.Get when the passkey is
+// not in the allowed credentials.
+var ErrCredentialNotAllowed = errors.New("webauthntest: credential not allowed")
+
+// Authenticator holds a single passkey. Copy the value to simulate a cloned
+// authenticator.
+type Authenticator struct {
+	// Private.
+	alg          int
+	key          crypto.Signer
+	credentialID []byte
+	userHandle   []byte
+	rpID         string
+
+	// Public.
+	SignCount    uint32 // Incremented on every Get, unless Counterless.
+	Counterless  bool
+	UserVerified bool
+	BackedUp     bool
+	Format       string // Attestation format, "none" or "packed" (self).
+	Origin       string
+}
+
+// New returns a pointer to Authenticator that creates a passkey for the
+// COSE algorithm, for the origin, e.g. https://example.com.
+func New(alg int, origin string) (*Authenticator, error) {
+	var (
+		key crypto.Signer
+		err error
+	)
+
+	switch alg {
+	case webauthn.AlgES256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case webauthn.AlgRS256:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		return nil, webauthn.ErrAlgorithmUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	return &Authenticator{
+		alg:          alg,
+		key:          key,
+		credentialID: id,
+		UserVerified: true,
+		Format:       "none",
+		Origin:       origin,
+	}, nil
+}
+
+// CredentialID returns the ID of the passkey.
+func (a *Authenticator) CredentialID() []byte {
+	return a.credentialID
+}
+
+// Create registers the passkey, like navigator.credentials.create.
+func (a *Authenticator) Create(opts webauthn.CreationOptions) (webauthn.CredentialCreation, error) {
+	a.rpID = opts.RP.ID
+	a.userHandle = opts.User.ID
+
+	clientDataJSON, err := a.clientData("webauthn.create", opts.Challenge)
+	if err != nil {
+		return webauthn.CredentialCreation{}, err
+	}
+
+	authData := a.authenticatorData(true)
+	stmt := map[any]any{}
+	if a.Format == "packed" {
+		sig, err := a.sign(authData, clientDataJSON)
+		if err != nil {
+			return webauthn.CredentialCreation{}, err
+		}
+
+		stmt = map[any]any{"alg": a.alg, "sig": sig}
+	}
+
+	return webauthn.CredentialCreation{
+		ID:    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		RawID: a.credentialID,
+		Type:  "public-key",
+		Response: webauthn.AttestationResponse{
+			ClientDataJSON: clientDataJSON,
+			AttestationObject: encode(map[any]any{
+				"fmt":      a.Format,
+				"attStmt":  stmt,
+				"authData": authData,
+			}),
+			Transports: []string{"internal"},
+		},
+	}, nil
+}
+
+// Get signs the challenge with the passkey, like navigator.credentials.get.
+func (a *Authenticator) Get(opts webauthn.RequestOptions) (webauthn.CredentialAssertion, error) {
+	if len(opts.AllowCredentials) > 0 {
+		var allowed bool
+		for _, c := range opts.AllowCredentials {
+			allowed = allowed || bytes.Equal(c.ID, a.credentialID)
+		}
+
+		if !allowed {
+			return webauthn.CredentialAssertion{}, ErrCredentialNotAllowed
+		}
+	}
+
+	if !a.Counterless {
+		a.SignCount++
+	}
+
+	clientDataJSON, err := a.clientData("webauthn.get", opts.Challenge)
+	if err != nil {
+		return webauthn.CredentialAssertion{}, err
+	}
+
+	authData := a.authenticatorData(false)
+	sig, err := a.sign(authData, clientDataJSON)
+	if err != nil {
+		return webauthn.CredentialAssertion{}, err
+	}
+
+	return webauthn.CredentialAssertion{
+		ID:    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		RawID: a.credentialID,
+		Type:  "public-key",
+		Response: webauthn.AssertionResponse{
+			ClientDataJSON:    clientDataJSON,
+			AuthenticatorData: authData,
+			Signature:         sig,
+			UserHandle:        a.userHandle,
+		},
+	}, nil
+}
+
+func (a *Authenticator) clientData(typ string, challenge []byte) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":      typ,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    a.Origin,
+	})
+}
+
+func (a *Authenticator) authenticatorData(attested bool) []byte {
+	hash := sha256.Sum256([]byte(a.rpID))
+	flags := byte(0x01 | 0x08) // User present, backup eligible.
+	if a.UserVerified {
+		flags |= 0x04
+	}
+
+	if a.BackedUp {
+		flags |= 0x10
+	}
+
+	if attested {
+		flags |= 0x40
+	}
+
+	b := append(hash[:], flags)
+	b = binary.BigEndian.AppendUint32(b, a.SignCount)
+	if !attested {
+		return b
+	}
+
+	b = append(b, make([]byte, 16)...) // Zero AAGUID.
+	b = binary.BigEndian.AppendUint16(b, uint16(len(a.credentialID)))
+	b = append(b, a.credentialID...)
+	return append(b, a.publicKey()...)
+}
+
+// publicKey returns the COSE_Key of the passkey.
+func (a *Authenticator) publicKey() []byte {
+	switch k := a.key.Public().(type) {
+	case *ecdsa.PublicKey:
+		return encode(map[any]any{
+			1:  2,
+			3:  webauthn.AlgES256,
+			-1: 1,
+			-2: k.X.FillBytes(make([]byte, 32)),
+			-3: k.Y.FillBytes(make([]byte, 32)),
+		})
+	case *rsa.PublicKey:
+		return encode(map[any]any{
+			1:  3,
+			3:  webauthn.AlgRS256,
+			-1: k.N.Bytes(),
+			-2: big.NewInt(int64(k.E)).Bytes(),
+		})
+	}
+
+	return nil
+}
+
+func (a *Authenticator) sign(authData, clientDataJSON []byte) ([]byte, error) {
+	hash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), hash[:]...))
+
+	return a.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
@@ -0,0 +1,49 @@
+package webauthntest
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// encode encodes the value as CBOR. Only the types used by the authenticator
+// are supported.
+func encode(v any) []byte {
+	switch v := v.(type) {
+	case int:
+		if v < 0 {
+			return header(1, uint64(-1-v))
+		}
+
+		return header(0, uint64(v))
+	case []byte:
+		return append(header(2, uint64(len(v))), v...)
+	case string:
+		return append(header(3, uint64(len(v))), v...)
+	case map[any]any:
+		b := header(5, uint64(len(v)))
+		for k, e := range v {
+			b = append(b, encode(k)...)
+			b = append(b, encode(e)...)
+		}
+
+		return b
+	}
+
+	panic(fmt.Sprintf("webauthntest: cannot encode %T", v))
+}
+
+func header(major byte, n uint64) []byte {
+	m := major << 5
+	switch {
+	case n < 24:
+		return []byte{m | byte(n)}
+	case n <= 0xff:
+		return []byte{m | 24, byte(n)}
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{m | 25}, uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{m | 26}, uint32(n))
+	}
+
+	return binary.BigEndian.AppendUint64([]byte{m | 27}, n)
+}
@@ -0,0 +1,319 @@
+package domain
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Breach errors.
+var (
+	ErrBreachPrefixInvalid = errors.New("breach: invalid hash prefix")
+	ErrBloomFilterInvalid  = errors.New("breach: invalid bloom filter")
+	ErrBreachHashInvalid   = errors.New("breach: invalid hash")
+)
+
+// BreachPrefixLen is the number of hex characters of the SHA-1 hash that are
+// sent in a range query.
+const BreachPrefixLen = 5
+
+// BreachChecker checks if a password is known from breaches.
+type BreachChecker interface {
+	Breached(ctx context.Context, p Plaintext) (bool, error)
+}
+
+// PasswordRange queries breached passwords with the k-anonymity range protocol
+// of Pwned Passwords. Only the first 5 hex characters of the SHA-1 hash are
+// sent, and the suffixes of all breached hashes with the prefix are returned,
+// with the number of times they were seen.
+//
+// Reference:
+// https://haveibeenpwned.com/API/v3#SearchingPwnedPasswordsByRange
+type PasswordRange interface {
+	Range(ctx context.Context, prefix string) (map[string]int, error)
+}
+
+// RangeChecker is a BreachChecker backed by a PasswordRange, e.g. an HTTP
+// client for the Pwned Passwords API, or a SortedHashFile.
+type RangeChecker struct {
+	Range PasswordRange
+
+	// MinCount is the minimum number of times the password is seen to be
+	// considered breached. Defaults to 1.
+	MinCount int
+}
+
+// Breached implements BreachChecker.
+func (c RangeChecker) Breached(ctx context.Context, p Plaintext) (bool, error) {
+	hash := breachHash(p)
+	suffixes, err := c.Range.Range(ctx, hash[:BreachPrefixLen])
+	if err != nil {
+		return false, err
+	}
+
+	minCount := c.MinCount
+	if minCount <= 0 {
+		minCount = 1
+	}
+
+	return suffixes[hash[BreachPrefixLen:]] >= minCount, nil
+}
+
+// breachHash returns the uppercase hex SHA-1 hash of the password.
+func breachHash(p Plaintext) string {
+	sum := sha1.Sum([]byte(p))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// SortedHashFile is a local PasswordRange, backed by a file of SHA-1 hashes
+// sorted in ascending order, one HASH:COUNT per line. This is the format of
+// the downloadable Pwned Passwords file ordered by hash. Lookups use binary
+// search, so the file does not need to be loaded into memory.
+type SortedHashFile struct {
+	r    io.ReaderAt
+	size int64
+}
+
+// NewSortedHashFile returns a SortedHashFile that reads size bytes from r,
+// e.g. an *os.File.
+func NewSortedHashFile(r io.ReaderAt, size int64) *SortedHashFile {
+	return &SortedHashFile{r: r, size: size}
+}
+
+// Range implements PasswordRange.
+func (f *SortedHashFile) Range(ctx context.Context, prefix string) (map[string]int, error) {
+	prefix = strings.ToUpper(prefix)
+	if len(prefix) != BreachPrefixLen {
+		return nil, ErrBreachPrefixInvalid
+	}
+
+	if _, err := hex.DecodeString(prefix + "0"); err != nil {
+		return nil, ErrBreachPrefixInvalid
+	}
+
+	// Find the first offset where the next line is not before the prefix.
+	lo, hi := int64(0), f.size
+	for lo < hi {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		mid := lo + (hi-lo)/2
+		start, err := f.nextLine(mid)
+		if err != nil {
+			return nil, err
+		}
+
+		hash, _, err := f.readHash(start)
+		if err != nil {
+			return nil, err
+		}
+
+		if hash != "" && hash[:BreachPrefixLen] < prefix {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	start, err := f.nextLine(lo)
+	if err != nil {
+		return nil, err
+	}
+
+	suffixes := make(map[string]int)
+	scanner := bufio.NewScanner(io.NewSectionReader(f.r, start, f.size-start))
+	for scanner.Scan() {
+		hash, count, err := parseHashLine(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+
+		if !strings.HasPrefix(hash, prefix) {
+			break
+		}
+
+		suffixes[hash[BreachPrefixLen:]] = count
+	}
+
+	return suffixes, scanner.Err()
+}
+
+// nextLine returns the offset of the first line that starts at or after off.
+func (f *SortedHashFile) nextLine(off int64) (int64, error) {
+	if off <= 0 {
+		return 0, nil
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(f.r, off-1, f.size-off+1))
+	b, err := r.ReadString('\n')
+	if errors.Is(err, io.EOF) {
+		return f.size, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return off - 1 + int64(len(b)), nil
+}
+
+// readHash reads the hash of the line at off. The hash is empty at the end of
+// the file.
+func (f *SortedHashFile) readHash(off int64) (string, int, error) {
+	if off >= f.size {
+		return "", 0, nil
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(f.r, off, f.size-off))
+	line, err := r.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", 0, err
+	}
+
+	return parseHashLine(line)
+}
+
+// parseHashLine parses HASH:COUNT, or HASH with a count of 1. Blank lines
+// return an empty hash.
+func parseHashLine(line string) (string, int, error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", 0, nil
+	}
+
+	hash, count, found := strings.Cut(line, ":")
+	if len(hash) != sha1.Size*2 {
+		return "", 0, ErrBreachHashInvalid
+	}
+
+	n := 1
+	if found {
+		var err error
+		n, err = strconv.Atoi(count)
+		if err != nil {
+			return "", 0, fmt.Errorf("%w: %w", ErrBreachHashInvalid, err)
+		}
+	}
+
+	return strings.ToUpper(hash), n, nil
+}
+
+// BloomFilter is a local BreachChecker that trades a small false positive rate
+// for a compact size, e.g. about 1.8 GB for a billion hashes at 0.1%. False
+// positives only reject a few more passwords, and there are no false
+// negatives.
+type BloomFilter struct {
+	k    uint32
+	bits []uint64
+}
+
+// NewBloomFilter returns a BloomFilter sized for n hashes with the false
+// positive rate p. It returns ErrBloomFilterInvalid when n is not positive, or
+// p is not between 0 and 1.
+func NewBloomFilter(n int, p float64) (*BloomFilter, error) {
+	if n <= 0 || !(p > 0 && p < 1) {
+		return nil, ErrBloomFilterInvalid
+	}
+
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := math.Round(m / float64(n) * math.Ln2)
+
+	return &BloomFilter{
+		k:    uint32(math.Max(k, 1)),
+		bits: make([]uint64, int(math.Max(m, 64)+63)/64),
+	}, nil
+}
+
+// Add adds the password.
+func (b *BloomFilter) Add(p Plaintext) {
+	sum := sha1.Sum([]byte(p))
+	b.add(sum[:])
+}
+
+// AddHash adds the hex SHA-1 hash of a password, e.g. from a Pwned Passwords
+// file.
+func (b *BloomFilter) AddHash(hash string) error {
+	sum, err := hex.DecodeString(hash)
+	if err != nil || len(sum) != sha1.Size {
+		return ErrBreachHashInvalid
+	}
+
+	b.add(sum)
+	return nil
+}
+
+// Breached implements BreachChecker.
+func (b *BloomFilter) Breached(ctx context.Context, p Plaintext) (bool, error) {
+	sum := sha1.Sum([]byte(p))
+	for _, i := range b.indexes(sum[:]) {
+		if b.bits[i/64]&(1<<(i%64)) == 0 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (b *BloomFilter) add(sum []byte) {
+	for _, i := range b.indexes(sum) {
+		b.bits[i/64] |= 1 << (i % 64)
+	}
+}
+
+// indexes uses double hashing on the SHA-1 hash, which is already uniformly
+// distributed.
+func (b *BloomFilter) indexes(sum []byte) []uint64 {
+	h1 := binary.BigEndian.Uint64(sum[0:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16]) | 1
+	m := uint64(len(b.bits)) * 64
+
+	idx := make([]uint64, b.k)
+	for i := range idx {
+		idx[i] = (h1 + uint64(i)*h2) % m
+	}
+
+	return idx
+}
+
+// MarshalBinary encodes the filter, so that it can be built once and saved to a
+// file.
+func (b *BloomFilter) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 4+len(b.bits)*8)
+	binary.BigEndian.PutUint32(buf, b.k)
+	for i, w := range b.bits {
+		binary.BigEndian.PutUint64(buf[4+i*8:], w)
+	}
+
+	return buf, nil
+}
+
+// UnmarshalBinary decodes the filter encoded by MarshalBinary.
+func (b *BloomFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < 4+8 || (len(data)-4)%8 != 0 {
+		return ErrBloomFilterInvalid
+	}
+
+	k := binary.BigEndian.Uint32(data)
+	if k == 0 {
+		return ErrBloomFilterInvalid
+	}
+
+	bits := make([]uint64, (len(data)-4)/8)
+	for i := range bits {
+		bits[i] = binary.BigEndian.Uint64(data[4+i*8:])
+	}
+
+	b.k = k
+	b.bits = bits
+
+	return nil
+}
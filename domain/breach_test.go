@@ -0,0 +1,162 @@
+package domain_test
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var breachedPasswords = []string{
+	"password",
+	"123456",
+	"qwerty",
+	"letmein",
+	"iloveyou",
+	"monkey",
+	"dragon",
+	"sunshine",
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func newSortedHashFile(t *testing.T) *domain.SortedHashFile {
+	t.Helper()
+
+	lines := make([]string, len(breachedPasswords))
+	for i, p := range breachedPasswords {
+		lines[i] = fmt.Sprintf("%s:%d", sha1Hex(p), i+1)
+	}
+	sort.Strings(lines)
+
+	data := strings.Join(lines, "\r\n") + "\r\n"
+	return domain.NewSortedHashFile(strings.NewReader(data), int64(len(data)))
+}
+
+func TestSortedHashFile(t *testing.T) {
+	assert := assert.New(t)
+
+	f := newSortedHashFile(t)
+	ctx := context.Background()
+
+	for i, p := range breachedPasswords {
+		hash := sha1Hex(p)
+		suffixes, err := f.Range(ctx, strings.ToLower(hash[:domain.BreachPrefixLen]))
+		assert.Nil(err)
+		assert.Equal(i+1, suffixes[hash[domain.BreachPrefixLen:]], p)
+	}
+
+	suffixes, err := f.Range(ctx, "00000")
+	assert.Nil(err)
+	assert.Empty(suffixes)
+
+	suffixes, err = f.Range(ctx, "FFFFF")
+	assert.Nil(err)
+	assert.Empty(suffixes)
+
+	_, err = f.Range(ctx, "XYZ12")
+	assert.ErrorIs(err, domain.ErrBreachPrefixInvalid)
+
+	_, err = f.Range(ctx, "ABCDEF")
+	assert.ErrorIs(err, domain.ErrBreachPrefixInvalid)
+}
+
+func TestSortedHashFileInvalid(t *testing.T) {
+	data := "not a hash\n"
+	f := domain.NewSortedHashFile(strings.NewReader(data), int64(len(data)))
+
+	_, err := f.Range(context.Background(), "ABCDE")
+	assert.ErrorIs(t, err, domain.ErrBreachHashInvalid)
+}
+
+func TestRangeChecker(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	checker := domain.RangeChecker{Range: newSortedHashFile(t)}
+
+	for _, p := range breachedPasswords {
+		breached, err := checker.Breached(ctx, domain.Plaintext(p))
+		assert.Nil(err)
+		assert.True(breached, p)
+	}
+
+	breached, err := checker.Breached(ctx, "correcthorsebatterystaple")
+	assert.Nil(err)
+	assert.False(breached)
+
+	// "password" is seen once, "sunshine" 8 times.
+	checker.MinCount = 2
+	breached, err = checker.Breached(ctx, "password")
+	assert.Nil(err)
+	assert.False(breached)
+
+	breached, err = checker.Breached(ctx, "sunshine")
+	assert.Nil(err)
+	assert.True(breached)
+}
+
+func TestBloomFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	bf, err := domain.NewBloomFilter(len(breachedPasswords), 0.001)
+	assert.Nil(err)
+
+	for i, p := range breachedPasswords {
+		if i%2 == 0 {
+			bf.Add(domain.Plaintext(p))
+		} else {
+			assert.Nil(bf.AddHash(sha1Hex(p)))
+		}
+	}
+
+	assert.ErrorIs(bf.AddHash("invalid"), domain.ErrBreachHashInvalid)
+
+	data, err := bf.MarshalBinary()
+	assert.Nil(err)
+
+	var decoded domain.BloomFilter
+	assert.Nil(decoded.UnmarshalBinary(data))
+	assert.ErrorIs(decoded.UnmarshalBinary(data[:3]), domain.ErrBloomFilterInvalid)
+
+	for _, checker := range []domain.BreachChecker{bf, &decoded} {
+		for _, p := range breachedPasswords {
+			breached, err := checker.Breached(ctx, domain.Plaintext(p))
+			assert.Nil(err)
+			assert.True(breached, p)
+		}
+
+		breached, err := checker.Breached(ctx, "correcthorsebatterystaple")
+		assert.Nil(err)
+		assert.False(breached)
+	}
+}
+
+func TestNewBloomFilterInvalid(t *testing.T) {
+	testCases := []struct {
+		n int
+		p float64
+	}{
+		{0, 0.001},
+		{-1, 0.001},
+		{1, 0},
+		{1, 1},
+		{1, math.NaN()},
+	}
+
+	for _, tc := range testCases {
+		_, err := domain.NewBloomFilter(tc.n, tc.p)
+		assert.ErrorIs(t, err, domain.ErrBloomFilterInvalid, tc)
+	}
+}
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"unicode"
+)
+
+const deviceFingerprintLen = 16
+
+// DeviceFingerprint identifies the device or browser of the User across
+// logins.
+type DeviceFingerprint string
+
+// NewDeviceFingerprint returns the fingerprint of the device id, e.g. from a
+// long-lived cookie, and the user agent. The version numbers in the user
+// agent are ignored, so that browser updates do not change the fingerprint.
+func NewDeviceFingerprint(deviceID, userAgent string) DeviceFingerprint {
+	ua := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return -1
+		}
+
+		return unicode.ToLower(r)
+	}, userAgent)
+
+	sum := sha256.Sum256([]byte(deviceID + "\x00" + ua))
+	return DeviceFingerprint(base64.RawURLEncoding.EncodeToString(sum[:deviceFingerprintLen]))
+}
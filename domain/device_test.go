@@ -0,0 +1,26 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDeviceFingerprint(t *testing.T) {
+	assert := assert.New(t)
+
+	chrome117 := "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/117.0.0.0 Safari/537.36"
+	chrome118 := "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Safari/537.36"
+	firefox := "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:109.0) Gecko/20100101 Firefox/118.0"
+
+	fp := domain.NewDeviceFingerprint("device-1", chrome117)
+	assert.NotEmpty(fp)
+	assert.NotContains(string(fp), "device-1")
+
+	// Browser updates do not change the fingerprint.
+	assert.Equal(fp, domain.NewDeviceFingerprint("device-1", chrome118))
+
+	assert.NotEqual(fp, domain.NewDeviceFingerprint("device-1", firefox))
+	assert.NotEqual(fp, domain.NewDeviceFingerprint("device-2", chrome117))
+}
@@ -0,0 +1,108 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"time"
+)
+
+const earthRadiusKm = 6371
+
+var ErrLocationNotFound = errors.New("geo: location not found")
+
+// GeoLocation is the approximate location of an IP address.
+type GeoLocation struct {
+	Country   string
+	Latitude  float64
+	Longitude float64
+}
+
+// IsZero returns true if the location is unknown.
+func (l GeoLocation) IsZero() bool {
+	return l == GeoLocation{}
+}
+
+// DistanceKm returns the great-circle distance between the locations, using
+// the haversine formula.
+func (l GeoLocation) DistanceKm(other GeoLocation) float64 {
+	lat1 := l.Latitude * math.Pi / 180
+	lat2 := other.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (other.Longitude - l.Longitude) * math.Pi / 180
+
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// GeoLocator finds the location of an IP address, e.g. with a GeoIP
+// database. Return ErrLocationNotFound when the location is unknown.
+type GeoLocator interface {
+	Locate(ctx context.Context, ip string) (GeoLocation, error)
+}
+
+// GeoTable is a GeoLocator backed by a lookup table of CIDR to location, for
+// tests and private networks. The longest matching prefix wins.
+type GeoTable map[string]GeoLocation
+
+// Locate implements GeoLocator.
+func (t GeoTable) Locate(ctx context.Context, ip string) (GeoLocation, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return GeoLocation{}, ErrLocationNotFound
+	}
+
+	var loc GeoLocation
+	longest := -1
+	for cidr, l := range t {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return GeoLocation{}, err
+		}
+
+		ones, _ := network.Mask.Size()
+		if network.Contains(addr) && ones > longest {
+			loc = l
+			longest = ones
+		}
+	}
+
+	if longest < 0 {
+		return GeoLocation{}, ErrLocationNotFound
+	}
+
+	return loc, nil
+}
+
+// GeoVelocityPolicy detects logins from locations that are too far apart to
+// travel between in the elapsed time.
+type GeoVelocityPolicy struct {
+	// MaxSpeedKmh is the fastest plausible travel speed.
+	MaxSpeedKmh float64
+
+	// Distances up to MinDistanceKm are ignored, since IP locations are
+	// approximate.
+	MinDistanceKm float64
+}
+
+// DefaultGeoVelocityPolicy allows travel by airliner.
+var DefaultGeoVelocityPolicy = GeoVelocityPolicy{
+	MaxSpeedKmh:   1000,
+	MinDistanceKm: 500,
+}
+
+// ImpossibleTravel returns true if going from one location to the other in
+// elapsed is faster than MaxSpeedKmh.
+func (p GeoVelocityPolicy) ImpossibleTravel(from, to GeoLocation, elapsed time.Duration) bool {
+	if from.IsZero() || to.IsZero() {
+		return false
+	}
+
+	d := from.DistanceKm(to)
+	if d <= p.MinDistanceKm {
+		return false
+	}
+
+	return d/elapsed.Hours() > p.MaxSpeedKmh
+}
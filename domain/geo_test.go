@@ -0,0 +1,69 @@
+package domain_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	kualaLumpur = domain.GeoLocation{Country: "MY", Latitude: 3.139, Longitude: 101.6869}
+	singapore   = domain.GeoLocation{Country: "SG", Latitude: 1.3521, Longitude: 103.8198}
+	london      = domain.GeoLocation{Country: "GB", Latitude: 51.5072, Longitude: -0.1276}
+)
+
+func TestGeoLocationDistanceKm(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.InDelta(0, kualaLumpur.DistanceKm(kualaLumpur), 0.001)
+	assert.InDelta(310, kualaLumpur.DistanceKm(singapore), 10)
+	assert.InDelta(10560, kualaLumpur.DistanceKm(london), 50)
+	assert.Equal(kualaLumpur.DistanceKm(london), london.DistanceKm(kualaLumpur))
+}
+
+func TestGeoTable(t *testing.T) {
+	assert := assert.New(t)
+
+	table := domain.GeoTable{
+		"203.0.113.0/24":   kualaLumpur,
+		"203.0.113.128/25": singapore,
+		"2001:db8::/32":    london,
+	}
+
+	ctx := context.Background()
+	loc, err := table.Locate(ctx, "203.0.113.1")
+	assert.Nil(err)
+	assert.Equal(kualaLumpur, loc)
+
+	// The longest prefix wins.
+	loc, err = table.Locate(ctx, "203.0.113.200")
+	assert.Nil(err)
+	assert.Equal(singapore, loc)
+
+	loc, err = table.Locate(ctx, "2001:db8::1")
+	assert.Nil(err)
+	assert.Equal(london, loc)
+
+	_, err = table.Locate(ctx, "198.51.100.1")
+	assert.ErrorIs(err, domain.ErrLocationNotFound)
+
+	_, err = table.Locate(ctx, "invalid")
+	assert.ErrorIs(err, domain.ErrLocationNotFound)
+}
+
+func TestGeoVelocityPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	p := domain.DefaultGeoVelocityPolicy
+	assert.True(p.ImpossibleTravel(kualaLumpur, london, time.Hour))
+	assert.False(p.ImpossibleTravel(kualaLumpur, london, 14*time.Hour))
+
+	// Nearby locations are ignored, since IP locations are approximate.
+	assert.False(p.ImpossibleTravel(kualaLumpur, singapore, time.Minute))
+
+	// Unknown locations are ignored.
+	assert.False(p.ImpossibleTravel(domain.GeoLocation{}, london, time.Minute))
+}
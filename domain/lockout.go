@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"math"
+	"time"
+)
+
+// LockoutPolicy locks out a key, such as an email or an IP address, after too
+// many failed logins. Every failure past the threshold doubles the lockout
+// duration, up to MaxDuration. Zero MaxDuration does not cap the duration.
+type LockoutPolicy struct {
+	Threshold   int
+	Duration    time.Duration
+	MaxDuration time.Duration
+
+	// Window is the time without failures after which the counter is
+	// forgotten, for counters that are not reset on success, e.g. by IP
+	// address. Zero never forgets.
+	Window time.Duration
+}
+
+// DefaultLockoutPolicy locks out for 1 minute after 5 failures, up to 1 day,
+// and forgets the failures after 1 day.
+var DefaultLockoutPolicy = LockoutPolicy{
+	Threshold:   5,
+	Duration:    time.Minute,
+	MaxDuration: 24 * time.Hour,
+	Window:      24 * time.Hour,
+}
+
+// FailedLogins is the failure counter of a key.
+type FailedLogins struct {
+	Count        int
+	LastFailedAt time.Time
+}
+
+// LockedUntil returns the time the key is locked out until. The zero time is
+// returned when the key is not locked out.
+func (p LockoutPolicy) LockedUntil(f FailedLogins) time.Time {
+	if p.Threshold <= 0 || f.Count < p.Threshold {
+		return time.Time{}
+	}
+
+	maxDuration := p.MaxDuration
+	if maxDuration <= 0 {
+		maxDuration = math.MaxInt64
+	}
+
+	d := p.Duration
+	for i := p.Threshold; i < f.Count && d < maxDuration; i++ {
+		if d > maxDuration/2 {
+			d = maxDuration
+			break
+		}
+		d *= 2
+	}
+
+	if d > maxDuration {
+		d = maxDuration
+	}
+
+	return f.LastFailedAt.Add(d)
+}
+
+// Expired returns true if the last failure is older than the Window, and the
+// counter should start again from zero.
+func (p LockoutPolicy) Expired(f FailedLogins, now time.Time) bool {
+	return p.Window > 0 && f.Count > 0 && !now.Before(f.LastFailedAt.Add(p.Window))
+}
@@ -0,0 +1,75 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockoutPolicy(t *testing.T) {
+	policy := domain.LockoutPolicy{
+		Threshold:   3,
+		Duration:    time.Minute,
+		MaxDuration: 10 * time.Minute,
+	}
+
+	now := time.Now()
+
+	testCases := []struct {
+		count int
+		want  time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, 1 * time.Minute},
+		{4, 2 * time.Minute},
+		{5, 4 * time.Minute},
+		{6, 8 * time.Minute},
+		{7, 10 * time.Minute},
+		{100, 10 * time.Minute},
+	}
+
+	for _, tc := range testCases {
+		until := policy.LockedUntil(domain.FailedLogins{
+			Count:        tc.count,
+			LastFailedAt: now,
+		})
+
+		if tc.want == 0 {
+			assert.True(t, until.IsZero(), tc.count)
+			continue
+		}
+
+		assert.Equal(t, now.Add(tc.want), until, tc.count)
+	}
+}
+
+func TestLockoutPolicyNoMaxDuration(t *testing.T) {
+	assert := assert.New(t)
+
+	policy := domain.LockoutPolicy{Threshold: 3, Duration: time.Minute}
+
+	now := time.Now()
+	until := policy.LockedUntil(domain.FailedLogins{Count: 10, LastFailedAt: now})
+	assert.Equal(now.Add(128*time.Minute), until)
+
+	until = policy.LockedUntil(domain.FailedLogins{Count: 1000, LastFailedAt: now})
+	assert.True(until.After(now.Add(100 * 365 * 24 * time.Hour)))
+}
+
+func TestLockoutPolicyExpired(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	f := domain.FailedLogins{Count: 3, LastFailedAt: now.Add(-time.Hour)}
+
+	policy := domain.LockoutPolicy{Threshold: 3, Duration: time.Minute}
+	assert.False(policy.Expired(f, now))
+
+	policy.Window = time.Hour
+	assert.True(policy.Expired(f, now))
+	assert.False(policy.Expired(f, now.Add(-time.Second)))
+	assert.False(policy.Expired(domain.FailedLogins{}, now))
+}
@@ -1,18 +1,13 @@
 package domain
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
-	"unicode/utf8"
 
 	"github.com/alextanhongpin/passwd"
 )
 
-// PasswordMinLen rules
-const (
-	PasswordMinLen = 8
-	PasswordMaxLen = 128 // Max length of argon2 (?)
-)
-
 // Password errors.
 var (
 	ErrPasswordTooShort = errors.New("password: too short")
@@ -22,31 +17,45 @@ var (
 // Plaintext representation of password.
 type Plaintext string
 
-// Validate validates the plaintext min length.
+// Validate validates the plaintext length against the DefaultPasswordPolicy.
+// It is also used for existing passwords, e.g. in Login, so the other rules are
+// only checked for new passwords with PasswordPolicy.Check. Otherwise
+// tightening them would lock out the Users with older passwords.
 func (p Plaintext) Validate() error {
-	n := utf8.RuneCountInString(string(p))
-	if n < PasswordMinLen {
-		return ErrPasswordTooShort
-	}
-
-	if n > PasswordMaxLen {
-		return ErrPasswordTooLong
+	policy := PasswordPolicy{
+		MinLen: DefaultPasswordPolicy.MinLen,
+		MaxLen: DefaultPasswordPolicy.MaxLen,
 	}
 
-	return nil
+	return policy.Check(p, "", "")
 }
 
 func (p Plaintext) String() string {
 	return "*PASSWORD REDACTED*"
 }
 
-// Encrypt encrypts a plaintext into cipthertext.
+// Encrypt encrypts a plaintext into cipthertext, using the hasher set by
+// UsePasswordHasher and the pepper set by UsePeppers.
 func (p Plaintext) Encrypt() (Ciphertext, error) {
-	ciphertext, err := passwd.Encrypt([]byte(p))
+	if p == "" {
+		return "", ErrPasswordTooShort
+	}
+
+	pepper, peppered := currentPepper()
+	if peppered {
+		p = pepper.apply(p)
+	}
+
+	_, hasher := currentPasswordHasher()
+	ciphertext, err := hasher.Hash([]byte(p))
 	if err != nil {
 		return "", err
 	}
 
+	if peppered {
+		return Ciphertext(pepperPrefix + pepper.ID + ciphertext), nil
+	}
+
 	return Ciphertext(ciphertext), nil
 }
 
@@ -59,7 +68,75 @@ func (p Plaintext) Equal(other Plaintext) bool {
 type Ciphertext string
 
 // Compare checks if the cipthertext is derived from the plaintext password.
+// The hasher is chosen by the PHC identifier of the ciphertext, and the pepper
+// by the embedded pepper ID.
 func (c Ciphertext) Compare(p Plaintext) bool {
-	match, err := passwd.Compare(string(c), []byte(p))
+	id, c := splitPepper(c)
+	if id != "" {
+		pepper, ok := pepperByID(id)
+		if !ok {
+			return false
+		}
+
+		p = pepper.apply(p)
+	}
+
+	_, hasher, err := passwordHasherFor(string(c))
+	if err != nil {
+		return false
+	}
+
+	match, err := hasher.Compare(string(c), []byte(p))
 	return err == nil && match
 }
+
+// NeedsRehash returns true if the ciphertext is not encrypted by the current
+// hasher and its parameters, or the current pepper. The password should be
+// encrypted again the next time the plaintext is available, e.g. after a
+// successful login.
+func (c Ciphertext) NeedsRehash() bool {
+	pepperID, c := splitPepper(c)
+	if pepper, _ := currentPepper(); pepper.ID != pepperID {
+		return true
+	}
+
+	id, hasher, err := passwordHasherFor(string(c))
+	if err != nil {
+		return true
+	}
+
+	current, _ := currentPasswordHasher()
+	return id != current || hasher.NeedsRehash(string(c))
+}
+
+// DummyCiphertext returns a precomputed ciphertext of a random password,
+// encrypted by the current hasher. Compare against it when there is no
+// ciphertext, e.g. the User does not exist, so that the response time does not
+// reveal it.
+func DummyCiphertext() Ciphertext {
+	hashers.RLock()
+	c := hashers.dummy
+	hashers.RUnlock()
+	if c != "" {
+		return c
+	}
+
+	hashers.Lock()
+	defer hashers.Unlock()
+
+	if hashers.dummy == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			panic(err)
+		}
+
+		phc, err := hashers.byID[hashers.current].Hash([]byte(base64.StdEncoding.EncodeToString(b)))
+		if err != nil {
+			panic(err)
+		}
+
+		hashers.dummy = Ciphertext(phc)
+	}
+
+	return hashers.dummy
+}
@@ -0,0 +1,288 @@
+package domain
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/alextanhongpin/passwd"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// PasswordHasher errors.
+var (
+	ErrPasswordHasherNotFound = errors.New("password: hasher not found")
+	ErrCiphertextInvalid      = errors.New("password: invalid ciphertext")
+)
+
+// PasswordHasher hashes passwords into PHC formatted strings, e.g.
+// $argon2id$m=65536,t=2,p=4$salt$hash.
+//
+// Reference:
+// https://github.com/P-H-C/phc-string-format/blob/master/phc-sf-spec.md
+type PasswordHasher interface {
+	Hash(password []byte) (string, error)
+	Compare(phc string, password []byte) (bool, error)
+
+	// NeedsRehash returns true if the phc is hashed with different parameters.
+	NeedsRehash(phc string) bool
+}
+
+// Default hashers.
+var (
+	DefaultArgon2idHasher = Argon2idHasher{Time: 2, Memory: 64 * 1024, Parallelism: 4}
+	DefaultBcryptHasher   = BcryptHasher{Cost: bcrypt.DefaultCost}
+	DefaultScryptHasher   = ScryptHasher{LogN: 15, R: 8, P: 1}
+)
+
+var hashers = struct {
+	sync.RWMutex
+	byID    map[string]PasswordHasher
+	current string
+	dummy   Ciphertext
+}{
+	byID: map[string]PasswordHasher{
+		"argon2id": DefaultArgon2idHasher,
+		"2a":       DefaultBcryptHasher,
+		"2b":       DefaultBcryptHasher,
+		"2y":       DefaultBcryptHasher,
+		"scrypt":   DefaultScryptHasher,
+	},
+	current: "argon2id",
+}
+
+// RegisterPasswordHasher registers the hasher for the PHC identifier,
+// replacing the existing hasher.
+func RegisterPasswordHasher(id string, h PasswordHasher) {
+	hashers.Lock()
+	defer hashers.Unlock()
+
+	hashers.byID[id] = h
+	if id == hashers.current {
+		hashers.dummy = ""
+	}
+}
+
+// UsePasswordHasher sets the hasher used by Plaintext.Encrypt. Ciphertexts
+// hashed by other hashers can still be compared, but report NeedsRehash.
+func UsePasswordHasher(id string) error {
+	hashers.Lock()
+	defer hashers.Unlock()
+
+	if _, ok := hashers.byID[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrPasswordHasherNotFound, id)
+	}
+
+	hashers.current = id
+	hashers.dummy = ""
+
+	return nil
+}
+
+func currentPasswordHasher() (string, PasswordHasher) {
+	hashers.RLock()
+	defer hashers.RUnlock()
+
+	return hashers.current, hashers.byID[hashers.current]
+}
+
+func passwordHasherFor(phc string) (string, PasswordHasher, error) {
+	// The identifier is the first section, e.g. argon2id in
+	// $argon2id$m=65536,t=2,p=4$salt$hash.
+	parts := strings.SplitN(phc, "$", 3)
+	if len(parts) != 3 || parts[0] != "" {
+		return "", nil, ErrCiphertextInvalid
+	}
+
+	id := parts[1]
+
+	hashers.RLock()
+	defer hashers.RUnlock()
+
+	h, ok := hashers.byID[id]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %s", ErrPasswordHasherNotFound, id)
+	}
+
+	return id, h, nil
+}
+
+// Argon2idHasher hashes with argon2id. The ciphertext format is
+// $argon2id$m=65536,t=2,p=4$salt$hash.
+type Argon2idHasher struct {
+	Time        uint32
+	Memory      uint32 // In KiB.
+	Parallelism uint8
+}
+
+func (h Argon2idHasher) Hash(password []byte) (string, error) {
+	hasher := passwd.New(
+		passwd.Time(h.Time),
+		passwd.Memory(h.Memory),
+		passwd.Parallelism(h.Parallelism),
+	)
+
+	return hasher.Encrypt(password)
+}
+
+// Limits of the parameters of parsed argon2id ciphertexts, so that an imported
+// ciphertext cannot exhaust the memory or CPU when compared.
+const (
+	argon2idMaxTime        = 16
+	argon2idMaxMemory      = 1 << 20 // In KiB.
+	argon2idMaxParallelism = 16
+)
+
+func (h Argon2idHasher) Compare(phc string, password []byte) (bool, error) {
+	if _, err := h.parse(phc); err != nil {
+		return false, err
+	}
+
+	return passwd.Compare(phc, password)
+}
+
+func (h Argon2idHasher) NeedsRehash(phc string) bool {
+	params, err := h.parse(phc)
+	return err != nil || params != h
+}
+
+func (h Argon2idHasher) parse(phc string) (params Argon2idHasher, err error) {
+	parts := strings.Split(phc, "$")
+	if len(parts) != 5 || parts[1] != "argon2id" {
+		return params, ErrCiphertextInvalid
+	}
+
+	if _, err := fmt.Sscanf(parts[2], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Parallelism); err != nil {
+		return params, fmt.Errorf("%w: %w", ErrCiphertextInvalid, err)
+	}
+
+	if params.Time < 1 || params.Time > argon2idMaxTime ||
+		params.Memory < 1 || params.Memory > argon2idMaxMemory ||
+		params.Parallelism < 1 || params.Parallelism > argon2idMaxParallelism {
+		return params, fmt.Errorf("%w: argon2id parameters out of range", ErrCiphertextInvalid)
+	}
+
+	return params, nil
+}
+
+// BcryptHasher hashes with bcrypt. It is mainly used to compare legacy
+// ciphertexts, since bcrypt only uses the first 72 bytes of the password. The
+// ciphertext format is $2a$10$<salt><hash>.
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password []byte) (string, error) {
+	b, err := bcrypt.GenerateFromPassword(password, h.Cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+func (h BcryptHasher) Compare(phc string, password []byte) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(phc), password)
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (h BcryptHasher) NeedsRehash(phc string) bool {
+	cost, err := bcrypt.Cost([]byte(phc))
+	return err != nil || cost != h.Cost
+}
+
+// ScryptHasher hashes with scrypt. The ciphertext format is
+// $scrypt$ln=15,r=8,p=1$salt$hash.
+type ScryptHasher struct {
+	LogN uint8 // CPU/memory cost, N = 2^LogN.
+	R    int
+	P    int
+}
+
+const (
+	scryptSaltLen = 16
+	scryptKeyLen  = 32
+
+	// Limits of the parameters of parsed ciphertexts, so that an imported
+	// ciphertext cannot exhaust the memory or CPU when compared.
+	scryptMaxLogN   = 20
+	scryptMaxRP     = 64
+	scryptMaxMemory = 1 << 30 // 128 * r * N bytes.
+)
+
+var phcEncoding = base64.RawStdEncoding
+
+func (h ScryptHasher) Hash(password []byte) (string, error) {
+	salt := make([]byte, scryptSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key, err := scrypt.Key(password, salt, 1<<h.LogN, h.R, h.P, scryptKeyLen)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("$scrypt$ln=%d,r=%d,p=%d$%s$%s", h.LogN, h.R, h.P,
+		phcEncoding.EncodeToString(salt),
+		phcEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h ScryptHasher) Compare(phc string, password []byte) (bool, error) {
+	params, salt, key, err := h.parse(phc)
+	if err != nil {
+		return false, err
+	}
+
+	computed, err := scrypt.Key(password, salt, 1<<params.LogN, params.R, params.P, len(key))
+	if err != nil {
+		return false, err
+	}
+
+	return subtle.ConstantTimeCompare(key, computed) == 1, nil
+}
+
+func (h ScryptHasher) NeedsRehash(phc string) bool {
+	params, _, _, err := h.parse(phc)
+	return err != nil || params != h
+}
+
+func (h ScryptHasher) parse(phc string) (params ScryptHasher, salt, key []byte, err error) {
+	parts := strings.Split(phc, "$")
+	if len(parts) != 5 || parts[1] != "scrypt" {
+		return params, nil, nil, ErrCiphertextInvalid
+	}
+
+	if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &params.LogN, &params.R, &params.P); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %w", ErrCiphertextInvalid, err)
+	}
+
+	if params.LogN < 1 || params.LogN > scryptMaxLogN ||
+		params.R < 1 || params.R > scryptMaxRP || params.P < 1 || params.P > scryptMaxRP ||
+		params.R*params.P > scryptMaxRP ||
+		128*params.R<<params.LogN > scryptMaxMemory {
+		return params, nil, nil, fmt.Errorf("%w: scrypt parameters out of range", ErrCiphertextInvalid)
+	}
+
+	salt, err = phcEncoding.DecodeString(parts[3])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %w", ErrCiphertextInvalid, err)
+	}
+
+	key, err = phcEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %w", ErrCiphertextInvalid, err)
+	}
+
+	return params, salt, key, nil
+}
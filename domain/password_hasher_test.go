@@ -0,0 +1,107 @@
+package domain_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// bcrypt hash of 12345678 with cost 4, e.g. imported from a legacy system.
+const legacyBcrypt = "$2y$04$LhYfxpHduQk83tO.yck.quyO65.ylseVQkZul463aETiSUwddxXhe"
+
+func TestPasswordHasher(t *testing.T) {
+	pwd := domain.Plaintext("12345678")
+	other := domain.Plaintext("87654321")
+
+	hashers := map[string]domain.PasswordHasher{
+		"argon2id": domain.DefaultArgon2idHasher,
+		"2a":       domain.BcryptHasher{Cost: 4},
+		"scrypt":   domain.ScryptHasher{LogN: 10, R: 8, P: 1},
+	}
+
+	for id, hasher := range hashers {
+		id, hasher := id, hasher
+
+		t.Run(id, func(t *testing.T) {
+			assert := assert.New(t)
+
+			phc, err := hasher.Hash([]byte(pwd))
+			assert.Nil(err)
+			assert.True(strings.HasPrefix(phc, "$"+id+"$"), phc)
+			assert.False(hasher.NeedsRehash(phc))
+
+			match, err := hasher.Compare(phc, []byte(pwd))
+			assert.Nil(err)
+			assert.True(match)
+
+			match, err = hasher.Compare(phc, []byte(other))
+			assert.Nil(err)
+			assert.False(match)
+		})
+	}
+}
+
+func TestUsePasswordHasher(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Cleanup(func() {
+		assert.Nil(domain.UsePasswordHasher("argon2id"))
+	})
+
+	pwd := domain.Plaintext("12345678")
+	argon2id, err := pwd.Encrypt()
+	assert.Nil(err)
+
+	domain.RegisterPasswordHasher("scrypt", domain.ScryptHasher{LogN: 10, R: 8, P: 1})
+	assert.Nil(domain.UsePasswordHasher("scrypt"))
+	assert.ErrorIs(domain.UsePasswordHasher("md5"), domain.ErrPasswordHasherNotFound)
+
+	scrypt, err := pwd.Encrypt()
+	assert.Nil(err)
+	assert.True(strings.HasPrefix(string(scrypt), "$scrypt$ln=10,r=8,p=1$"))
+	assert.False(scrypt.NeedsRehash())
+	assert.True(scrypt.Compare(pwd))
+	assert.True(strings.HasPrefix(string(domain.DummyCiphertext()), "$scrypt$"))
+
+	// Ciphertexts from other hashers can still be compared, but should be
+	// migrated.
+	assert.True(argon2id.NeedsRehash())
+	assert.True(argon2id.Compare(pwd))
+}
+
+func TestLegacyCiphertext(t *testing.T) {
+	assert := assert.New(t)
+
+	ciphertext := domain.Ciphertext(legacyBcrypt)
+	assert.True(ciphertext.Compare("12345678"))
+	assert.False(ciphertext.Compare("87654321"))
+	assert.True(ciphertext.NeedsRehash())
+
+	assert.False(domain.Ciphertext("$md5$salt$hash").Compare("12345678"))
+	assert.False(domain.Ciphertext("plaintext").Compare("plaintext"))
+	assert.True(domain.Ciphertext("$md5$salt$hash").NeedsRehash())
+
+	// Scrypt parameters are bounded.
+	salt := "c2FsdHNhbHRzYWx0c2FsdA"
+	hash := "aGFzaGhhc2hoYXNoaGFzaGhhc2hoYXNoaGFzaGhhc2g"
+	for _, params := range []string{"ln=40,r=8,p=1", "ln=0,r=8,p=1", "ln=15,r=8,p=64", "ln=20,r=64,p=1", "ln=15,r=0,p=1", "ln=15,r=4611686018427387904,p=2"} {
+		ciphertext := domain.Ciphertext("$scrypt$" + params + "$" + salt + "$" + hash)
+		assert.False(ciphertext.Compare("12345678"), params)
+		assert.True(ciphertext.NeedsRehash(), params)
+
+		_, err := domain.DefaultScryptHasher.Compare(string(ciphertext), []byte("12345678"))
+		assert.ErrorIs(err, domain.ErrCiphertextInvalid, params)
+	}
+
+	// Argon2id parameters are bounded.
+	for _, params := range []string{"m=4294967295,t=1,p=1", "m=65536,t=4294967295,p=1", "m=65536,t=2,p=255", "m=0,t=2,p=4", "m=65536,t=0,p=4", "m=65536,t=2,p=0"} {
+		ciphertext := domain.Ciphertext("$argon2id$" + params + "$" + salt + "$" + hash)
+		assert.False(ciphertext.Compare("12345678"), params)
+		assert.True(ciphertext.NeedsRehash(), params)
+
+		_, err := domain.DefaultArgon2idHasher.Compare(string(ciphertext), []byte("12345678"))
+		assert.ErrorIs(err, domain.ErrCiphertextInvalid, params)
+	}
+}
@@ -0,0 +1,220 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Password policy errors, in addition to ErrPasswordTooShort and
+// ErrPasswordTooLong.
+var (
+	ErrPasswordMissingLower  = errors.New("password: missing lowercase letter")
+	ErrPasswordMissingUpper  = errors.New("password: missing uppercase letter")
+	ErrPasswordMissingDigit  = errors.New("password: missing digit")
+	ErrPasswordMissingSymbol = errors.New("password: missing symbol")
+	ErrPasswordRepeated      = errors.New("password: too many repeated characters")
+	ErrPasswordDenylisted    = errors.New("password: too common")
+	ErrPasswordPersonalInfo  = errors.New("password: contains email or name")
+	ErrPasswordTooWeak       = errors.New("password: too weak")
+)
+
+// PasswordStrengthError is the violation when the password strength score is
+// below PasswordPolicy.MinScore. The strength contains the feedback to show to
+// the User.
+type PasswordStrengthError struct {
+	Strength PasswordStrength
+}
+
+func (e *PasswordStrengthError) Error() string {
+	return ErrPasswordTooWeak.Error()
+}
+
+func (e *PasswordStrengthError) Unwrap() error {
+	return ErrPasswordTooWeak
+}
+
+// PasswordPolicyError contains all the rules violated by the password, so
+// that they can be shown together. Use errors.Is to check for a specific rule.
+type PasswordPolicyError struct {
+	Violations []error
+}
+
+func (e *PasswordPolicyError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, err := range e.Violations {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+func (e *PasswordPolicyError) Unwrap() []error {
+	return e.Violations
+}
+
+// PasswordPolicy are the rules a new password must satisfy. The zero value of
+// each rule disables it.
+type PasswordPolicy struct {
+	MinLen int // In runes.
+	MaxLen int // In runes.
+
+	RequireLower  bool
+	RequireUpper  bool
+	RequireDigit  bool
+	RequireSymbol bool
+
+	// MaxRepeated is the maximum number of consecutive identical characters.
+	MaxRepeated int
+
+	// Denylist of common passwords, compared case-insensitively.
+	Denylist []string
+
+	// RejectPersonalInfo rejects passwords that contain the email local-part or
+	// any part of the name.
+	RejectPersonalInfo bool
+
+	// MinScore is the minimum strength score from 0 to 4, see
+	// Plaintext.Strength.
+	MinScore int
+}
+
+// DefaultPasswordPolicy is the policy for new passwords. Plaintext.Validate
+// only checks the length rules.
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLen: 8,
+	MaxLen: 128,
+}
+
+// Check returns a *PasswordPolicyError with all the violated rules. The email
+// and name are optional, and only used by RejectPersonalInfo.
+func (pp PasswordPolicy) Check(p Plaintext, email Email, name string) error {
+	s := string(p)
+
+	var errs []error
+	n := utf8.RuneCountInString(s)
+	if pp.MinLen > 0 && n < pp.MinLen {
+		errs = append(errs, ErrPasswordTooShort)
+	}
+
+	if pp.MaxLen > 0 && n > pp.MaxLen {
+		errs = append(errs, ErrPasswordTooLong)
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range s {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+
+	if pp.RequireLower && !lower {
+		errs = append(errs, ErrPasswordMissingLower)
+	}
+
+	if pp.RequireUpper && !upper {
+		errs = append(errs, ErrPasswordMissingUpper)
+	}
+
+	if pp.RequireDigit && !digit {
+		errs = append(errs, ErrPasswordMissingDigit)
+	}
+
+	if pp.RequireSymbol && !symbol {
+		errs = append(errs, ErrPasswordMissingSymbol)
+	}
+
+	if pp.MaxRepeated > 0 && maxRepeated(s) > pp.MaxRepeated {
+		errs = append(errs, ErrPasswordRepeated)
+	}
+
+	lowered := strings.ToLower(s)
+	for _, d := range pp.Denylist {
+		if strings.ToLower(d) == lowered {
+			errs = append(errs, ErrPasswordDenylisted)
+			break
+		}
+	}
+
+	if pp.RejectPersonalInfo && containsPersonalInfo(lowered, email, name) {
+		errs = append(errs, ErrPasswordPersonalInfo)
+	}
+
+	// The estimate is skipped when the length rules fail, since it is slow for
+	// long passwords.
+	if pp.MinScore > 0 && (pp.MinLen <= 0 || n >= pp.MinLen) && (pp.MaxLen <= 0 || n <= pp.MaxLen) {
+		strength := p.Strength(personalInfo(email, name)...)
+		if strength.Score < pp.MinScore {
+			errs = append(errs, &PasswordStrengthError{Strength: strength})
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return &PasswordPolicyError{Violations: errs}
+}
+
+func maxRepeated(s string) int {
+	var longest, n int
+	var prev rune
+	for i, r := range s {
+		if i > 0 && r == prev {
+			n++
+		} else {
+			n = 1
+		}
+
+		if n > longest {
+			longest = n
+		}
+		prev = r
+	}
+
+	return longest
+}
+
+// Parts shorter than this are ignored, e.g. initials.
+const personalInfoMinLen = 3
+
+func containsPersonalInfo(password string, email Email, name string) bool {
+	for _, part := range personalInfo(email, name) {
+		if utf8.RuneCountInString(part) < personalInfoMinLen {
+			continue
+		}
+
+		if strings.Contains(password, part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// personalInfo returns the lowercased email local-part, and the parts of the
+// email local-part and name.
+func personalInfo(email Email, name string) []string {
+	local, _, _ := strings.Cut(strings.ToLower(string(email)), "@")
+
+	var parts []string
+	if local != "" {
+		parts = append(parts, local)
+	}
+	parts = append(parts, strings.FieldsFunc(local, isSeparator)...)
+	parts = append(parts, strings.FieldsFunc(strings.ToLower(name), isSeparator)...)
+
+	return parts
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
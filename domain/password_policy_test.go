@@ -0,0 +1,122 @@
+package domain_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := domain.PasswordPolicy{
+		MinLen:             8,
+		MaxLen:             64,
+		RequireLower:       true,
+		RequireUpper:       true,
+		RequireDigit:       true,
+		RequireSymbol:      true,
+		MaxRepeated:        2,
+		Denylist:           []string{"Passw0rd!"},
+		RejectPersonalInfo: true,
+	}
+
+	email := domain.Email("john.doe@mail.com")
+	name := "John Appleseed"
+
+	testCases := []struct {
+		name     string
+		password string
+		wantErrs []error
+	}{
+		{
+			name:     "valid",
+			password: "Tr0ub4dor&3",
+		},
+		{
+			name:     "too short",
+			password: "aA1!",
+			wantErrs: []error{domain.ErrPasswordTooShort},
+		},
+		{
+			name:     "missing character classes",
+			password: "correcthorse",
+			wantErrs: []error{
+				domain.ErrPasswordMissingUpper,
+				domain.ErrPasswordMissingDigit,
+				domain.ErrPasswordMissingSymbol,
+			},
+		},
+		{
+			name:     "repeated",
+			password: "Tr0ub4dooor&3",
+			wantErrs: []error{domain.ErrPasswordRepeated},
+		},
+		{
+			name:     "denylisted",
+			password: "passw0rd!",
+			wantErrs: []error{domain.ErrPasswordMissingUpper, domain.ErrPasswordDenylisted},
+		},
+		{
+			name:     "email local-part",
+			password: "Tr0ub4dor&Doe",
+			wantErrs: []error{domain.ErrPasswordPersonalInfo},
+		},
+		{
+			name:     "name",
+			password: "APPLESEED&3X",
+			wantErrs: []error{domain.ErrPasswordMissingLower, domain.ErrPasswordPersonalInfo},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			err := policy.Check(domain.Plaintext(tc.password), email, name)
+			if len(tc.wantErrs) == 0 {
+				assert.Nil(err)
+				return
+			}
+
+			var policyErr *domain.PasswordPolicyError
+			assert.True(errors.As(err, &policyErr))
+			assert.Equal(tc.wantErrs, policyErr.Violations)
+			for _, wantErr := range tc.wantErrs {
+				assert.ErrorIs(err, wantErr)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyZero(t *testing.T) {
+	var policy domain.PasswordPolicy
+	assert.Nil(t, policy.Check("", "", ""))
+}
+
+func TestPasswordPolicyMinScore(t *testing.T) {
+	assert := assert.New(t)
+
+	policy := domain.PasswordPolicy{MinScore: 3}
+	assert.Nil(policy.Check("correcthorsebatterystaple", "", ""))
+
+	err := policy.Check("qwertyuiop", "", "")
+	assert.ErrorIs(err, domain.ErrPasswordTooWeak)
+
+	var strengthErr *domain.PasswordStrengthError
+	assert.True(errors.As(err, &strengthErr))
+	assert.Equal(0, strengthErr.Strength.Score)
+	assert.NotEmpty(strengthErr.Strength.Warning)
+
+	// The name and email are used as user inputs.
+	assert.Nil(policy.Check("appleseed1990", "", ""))
+	assert.ErrorIs(policy.Check("appleseed1990", "john@mail.com", "John Appleseed"), domain.ErrPasswordTooWeak)
+
+	// The estimate is skipped when the length rules fail.
+	policy = domain.PasswordPolicy{MaxLen: 8, MinScore: 3}
+	err = policy.Check("correcthorsebatterystaple", "", "")
+	assert.ErrorIs(err, domain.ErrPasswordTooLong)
+	assert.NotErrorIs(err, domain.ErrPasswordTooWeak)
+}
@@ -0,0 +1,805 @@
+package domain
+
+import (
+	_ "embed"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// PasswordStrength is a zxcvbn-style estimate of how guessable a password is.
+//
+// Reference:
+// https://www.usenix.org/conference/usenixsecurity16/technical-sessions/presentation/wheeler
+type PasswordStrength struct {
+	// Score from 0 (too guessable) to 4 (very unguessable).
+	Score int
+
+	// Guesses is the estimated number of guesses to crack the password.
+	Guesses float64
+
+	// CrackTime is the estimated time to crack the password offline, against a
+	// slow hash at 10k guesses per second. It is capped at the max duration.
+	CrackTime time.Duration
+
+	Warning     string
+	Suggestions []string
+}
+
+// StrengthMaxLen is the number of runes of the password that Strength
+// estimates, since the estimate grows much faster than linearly with the
+// length. The rest only adds to the strength.
+const StrengthMaxLen = 100
+
+// Strength estimates the strength of the password. User inputs, such as the
+// email and name, are treated as the most common passwords. Only the first
+// StrengthMaxLen runes are estimated.
+func (p Plaintext) Strength(userInputs ...string) PasswordStrength {
+	rs := []rune(string(p))
+	if len(rs) > StrengthMaxLen {
+		rs = rs[:StrengthMaxLen]
+	}
+
+	inputs := make(map[string]int)
+	for i, s := range userInputs {
+		s = strings.ToLower(s)
+		if _, ok := inputs[s]; !ok && s != "" {
+			inputs[s] = i + 1
+		}
+	}
+
+	seq, guesses := estimateGuesses(rs, inputs)
+
+	var crackTime time.Duration
+	if secs := guesses / offlineSlowHashingPerSecond; secs < float64(math.MaxInt64)/float64(time.Second) {
+		crackTime = time.Duration(secs * float64(time.Second))
+	} else {
+		crackTime = time.Duration(math.MaxInt64)
+	}
+
+	score := guessesToScore(guesses)
+	warning, suggestions := strengthFeedback(score, seq)
+
+	return PasswordStrength{
+		Score:       score,
+		Guesses:     guesses,
+		CrackTime:   crackTime,
+		Warning:     warning,
+		Suggestions: suggestions,
+	}
+}
+
+const offlineSlowHashingPerSecond = 1e4
+
+func guessesToScore(guesses float64) int {
+	const delta = 5
+
+	switch {
+	case guesses < 1e3+delta:
+		return 0
+	case guesses < 1e6+delta:
+		return 1
+	case guesses < 1e8+delta:
+		return 2
+	case guesses < 1e10+delta:
+		return 3
+	default:
+		return 4
+	}
+}
+
+//go:embed data/common_passwords.txt
+var commonPasswordsTxt string
+
+// commonPasswords maps the common password to its rank, starting from 1.
+var commonPasswords = func() map[string]int {
+	ranks := make(map[string]int)
+	for _, s := range strings.Fields(commonPasswordsTxt) {
+		if _, ok := ranks[s]; !ok {
+			ranks[s] = len(ranks) + 1
+		}
+	}
+
+	return ranks
+}()
+
+// strengthMatch is a substring rs[i:j+1] of the password that matches a
+// pattern.
+type strengthMatch struct {
+	pattern string
+	i, j    int
+	guesses float64
+
+	// Dictionary.
+	rank        int
+	reversed    bool
+	l33t        bool
+	userInput   bool
+	allUpper    bool
+	capitalized bool
+
+	// Spatial.
+	turns int
+}
+
+const (
+	patternDictionary = "dictionary"
+	patternSpatial    = "spatial"
+	patternRepeat     = "repeat"
+	patternSequence   = "sequence"
+	patternYear       = "year"
+	patternBruteforce = "bruteforce"
+)
+
+// Guess estimation constants, following zxcvbn.
+const (
+	minGuessesSingleChar = 10
+	minGuessesMultiChar  = 50
+	minGuessesBeforeGrow = 10000
+	bruteforceCardinal   = 10
+	minYearSpace         = 20
+)
+
+// estimateGuesses returns the sequence of non-overlapping matches that covers
+// the password with the least guesses.
+func estimateGuesses(rs []rune, inputs map[string]int) ([]strengthMatch, float64) {
+	n := len(rs)
+	if n == 0 {
+		return nil, 1
+	}
+
+	matches := strengthMatches(rs, inputs)
+	for k := range matches {
+		m := &matches[k]
+		minGuesses := 1.0
+		if m.j-m.i+1 < n {
+			minGuesses = minGuessesMultiChar
+			if m.i == m.j {
+				minGuesses = minGuessesSingleChar
+			}
+		}
+
+		m.guesses = math.Max(m.guesses, minGuesses)
+	}
+
+	byJ := make([][]strengthMatch, n)
+	for _, m := range matches {
+		byJ[m.j] = append(byJ[m.j], m)
+	}
+
+	// optimal[k][l] is the best sequence of l matches that ends at k. The
+	// values are in log10 to avoid overflows.
+	type step struct {
+		m    strengthMatch
+		logP float64 // Product of the guesses.
+		logG float64 // Total guesses, l! * product + D^(l-1).
+	}
+	optimal := make([]map[int]step, n)
+	for k := range optimal {
+		optimal[k] = make(map[int]step)
+	}
+
+	update := func(m strengthMatch, l int) {
+		k := m.j
+		logP := math.Log10(m.guesses)
+		if l > 1 {
+			logP += optimal[m.i-1][l-1].logP
+		}
+
+		lf, _ := math.Lgamma(float64(l + 1))
+		logG := logAdd(lf/math.Ln10+logP, float64(l-1)*math.Log10(minGuessesBeforeGrow))
+
+		// Skip if a shorter sequence ending at k has lesser guesses.
+		for cl, s := range optimal[k] {
+			if cl <= l && s.logG <= logG {
+				return
+			}
+		}
+
+		optimal[k][l] = step{m: m, logP: logP, logG: logG}
+	}
+
+	bruteforce := func(i, j int) strengthMatch {
+		return strengthMatch{
+			pattern: patternBruteforce,
+			i:       i,
+			j:       j,
+			guesses: bruteforceGuesses(j - i + 1),
+		}
+	}
+
+	for k := 0; k < n; k++ {
+		for _, m := range byJ[k] {
+			if m.i == 0 {
+				update(m, 1)
+				continue
+			}
+
+			for l := range optimal[m.i-1] {
+				update(m, l+1)
+			}
+		}
+
+		update(bruteforce(0, k), 1)
+		for i := 1; i <= k; i++ {
+			m := bruteforce(i, k)
+			for l, s := range optimal[i-1] {
+				// Adjacent bruteforce matches are never optimal.
+				if s.m.pattern == patternBruteforce {
+					continue
+				}
+
+				update(m, l+1)
+			}
+		}
+	}
+
+	best, logG := 0, math.Inf(1)
+	for l := 1; l <= n; l++ {
+		if s, ok := optimal[n-1][l]; ok && s.logG < logG {
+			best, logG = l, s.logG
+		}
+	}
+
+	seq := make([]strengthMatch, best)
+	for k, l := n-1, best; l > 0; l-- {
+		m := optimal[k][l].m
+		seq[l-1] = m
+		k = m.i - 1
+	}
+
+	return seq, math.Pow(10, logG)
+}
+
+func logAdd(a, b float64) float64 {
+	if a < b {
+		a, b = b, a
+	}
+
+	return a + math.Log10(1+math.Pow(10, b-a))
+}
+
+func bruteforceGuesses(n int) float64 {
+	guesses := math.Pow(bruteforceCardinal, float64(n))
+	if n == 1 {
+		return minGuessesSingleChar + 1
+	}
+
+	return math.Max(guesses, minGuessesMultiChar+1)
+}
+
+func strengthMatches(rs []rune, inputs map[string]int) []strengthMatch {
+	var matches []strengthMatch
+	matches = append(matches, dictionaryMatches(rs, inputs)...)
+	matches = append(matches, spatialMatches(rs, qwertyGraph)...)
+	matches = append(matches, spatialMatches(rs, keypadGraph)...)
+	matches = append(matches, repeatMatches(rs, inputs)...)
+	matches = append(matches, sequenceMatches(rs)...)
+	matches = append(matches, yearMatches(rs)...)
+
+	return matches
+}
+
+// -- dictionary
+
+var l33tTable = map[rune]rune{
+	'4': 'a', '@': 'a',
+	'8': 'b',
+	'(': 'c',
+	'3': 'e',
+	'6': 'g',
+	'1': 'i', '!': 'i', '|': 'i',
+	'0': 'o',
+	'$': 's', '5': 's',
+	'7': 't', '+': 't',
+	'2': 'z',
+}
+
+func dictionaryMatches(rs []rune, inputs map[string]int) []strengthMatch {
+	lower := []rune(strings.ToLower(string(rs)))
+	if len(lower) != len(rs) {
+		// Casing changed the length, fallback to the original runes.
+		lower = rs
+	}
+
+	matches := rankedMatches(rs, lower, inputs)
+
+	// Reversed.
+	n := len(lower)
+	for _, m := range rankedMatches(reverseRunes(rs), reverseRunes(lower), inputs) {
+		m.i, m.j = n-1-m.j, n-1-m.i
+		m.reversed = true
+		m.guesses *= 2
+		matches = append(matches, m)
+	}
+
+	// L33t substitutions.
+	subbed := make([]rune, n)
+	for i, r := range lower {
+		if s, ok := l33tTable[r]; ok {
+			subbed[i] = s
+		} else {
+			subbed[i] = r
+		}
+	}
+
+	for _, m := range rankedMatches(rs, subbed, inputs) {
+		var subs int
+		for k := m.i; k <= m.j; k++ {
+			if subbed[k] != lower[k] {
+				subs++
+			}
+		}
+
+		// Not a l33t match if there are no substitutions, or it is a single
+		// character substitution.
+		if subs == 0 || m.j == m.i {
+			continue
+		}
+
+		m.l33t = true
+		m.guesses *= math.Pow(2, float64(subs))
+		matches = append(matches, m)
+	}
+
+	return matches
+}
+
+func rankedMatches(original, lower []rune, inputs map[string]int) []strengthMatch {
+	var matches []strengthMatch
+
+	n := len(lower)
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			word := string(lower[i : j+1])
+
+			rank, ok := inputs[word]
+			userInput := ok
+			if !ok {
+				rank, ok = commonPasswords[word]
+			}
+
+			if !ok {
+				continue
+			}
+
+			token := original[i : j+1]
+			matches = append(matches, strengthMatch{
+				pattern:     patternDictionary,
+				i:           i,
+				j:           j,
+				rank:        rank,
+				userInput:   userInput,
+				allUpper:    isAllUpper(token),
+				capitalized: unicode.IsUpper(token[0]),
+				guesses:     float64(rank) * uppercaseVariations(token),
+			})
+		}
+	}
+
+	return matches
+}
+
+func reverseRunes(rs []rune) []rune {
+	out := make([]rune, len(rs))
+	for i, r := range rs {
+		out[len(rs)-1-i] = r
+	}
+
+	return out
+}
+
+func isAllUpper(rs []rune) bool {
+	var letters int
+	for _, r := range rs {
+		if unicode.IsLower(r) {
+			return false
+		}
+
+		if unicode.IsUpper(r) {
+			letters++
+		}
+	}
+
+	return letters > 0
+}
+
+func uppercaseVariations(rs []rune) float64 {
+	var upper, lower int
+	for _, r := range rs {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+
+	if upper == 0 {
+		return 1
+	}
+
+	// Common capitalizations: first letter, last letter or all letters.
+	first, last := unicode.IsUpper(rs[0]), unicode.IsUpper(rs[len(rs)-1])
+	if lower == 0 || (upper == 1 && (first || last)) {
+		return 2
+	}
+
+	var variations float64
+	for i := 1; i <= upper && i <= lower; i++ {
+		variations += binomial(upper+lower, i)
+	}
+
+	return variations
+}
+
+func binomial(n, k int) float64 {
+	if k > n {
+		return 0
+	}
+
+	r := 1.0
+	for d := 1; d <= k; d++ {
+		r *= float64(n)
+		r /= float64(d)
+		n--
+	}
+
+	return r
+}
+
+// -- spatial
+
+type keyPosition struct {
+	row, col int
+	shifted  bool
+}
+
+type keyboardGraph struct {
+	keys       map[rune]keyPosition
+	grid       map[[2]int]bool
+	directions [][2]int
+	starts     float64
+	degree     float64
+}
+
+// Directions of adjacent keys on a staggered keyboard, where each row is
+// shifted right by half a key.
+var staggeredDirections = [][2]int{{0, -1}, {-1, 0}, {-1, 1}, {0, 1}, {1, 0}, {1, -1}}
+
+// Directions of adjacent keys on an aligned keypad.
+var alignedDirections = [][2]int{{0, -1}, {-1, -1}, {-1, 0}, {-1, 1}, {0, 1}, {1, 1}, {1, 0}, {1, -1}}
+
+var qwertyGraph = newKeyboardGraph(staggeredDirections,
+	[]string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./"},
+	[]string{"~!@#$%^&*()_+", "QWERTYUIOP{}|", "ASDFGHJKL:\"", "ZXCVBNM<>?"},
+)
+
+var keypadGraph = newKeyboardGraph(alignedDirections,
+	[]string{" /*-", "789+", "456", "123", " 0."},
+	nil,
+)
+
+func newKeyboardGraph(directions [][2]int, rows, shiftedRows []string) *keyboardGraph {
+	g := &keyboardGraph{
+		keys:       make(map[rune]keyPosition),
+		grid:       make(map[[2]int]bool),
+		directions: directions,
+	}
+
+	add := func(rows []string, shifted bool) {
+		for r, row := range rows {
+			for c, k := range []rune(row) {
+				if k == ' ' {
+					continue
+				}
+
+				g.keys[k] = keyPosition{row: r, col: c, shifted: shifted}
+				g.grid[[2]int{r, c}] = true
+			}
+		}
+	}
+	add(rows, false)
+	add(shiftedRows, true)
+
+	var degrees int
+	for pos := range g.grid {
+		for _, d := range directions {
+			if g.grid[[2]int{pos[0] + d[0], pos[1] + d[1]}] {
+				degrees++
+			}
+		}
+	}
+
+	g.starts = float64(len(g.keys))
+	g.degree = float64(degrees) / float64(len(g.grid))
+
+	return g
+}
+
+// direction returns the direction from key a to key b, or -1 if they are not
+// adjacent.
+func (g *keyboardGraph) direction(a, b rune) int {
+	pa, ok := g.keys[a]
+	if !ok {
+		return -1
+	}
+
+	pb, ok := g.keys[b]
+	if !ok {
+		return -1
+	}
+
+	for i, d := range g.directions {
+		if pa.row+d[0] == pb.row && pa.col+d[1] == pb.col {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func spatialMatches(rs []rune, g *keyboardGraph) []strengthMatch {
+	var matches []strengthMatch
+
+	n := len(rs)
+	for i := 0; i < n-2; {
+		j := i + 1
+		turns, last := 0, -1
+		for ; j < n; j++ {
+			d := g.direction(rs[j-1], rs[j])
+			if d < 0 {
+				break
+			}
+
+			if d != last {
+				turns++
+				last = d
+			}
+		}
+
+		if j-i > 2 {
+			var shifted int
+			for _, r := range rs[i:j] {
+				if g.keys[r].shifted {
+					shifted++
+				}
+			}
+
+			matches = append(matches, strengthMatch{
+				pattern: patternSpatial,
+				i:       i,
+				j:       j - 1,
+				turns:   turns,
+				guesses: spatialGuesses(g, j-i, turns, shifted),
+			})
+		}
+
+		i = j
+	}
+
+	return matches
+}
+
+func spatialGuesses(g *keyboardGraph, length, turns, shifted int) float64 {
+	var guesses float64
+	for i := 2; i <= length; i++ {
+		for j := 1; j <= turns && j <= i-1; j++ {
+			guesses += binomial(i-1, j-1) * g.starts * math.Pow(g.degree, float64(j))
+		}
+	}
+
+	if shifted > 0 {
+		unshifted := length - shifted
+		if unshifted == 0 {
+			guesses *= 2
+		} else {
+			var variations float64
+			for i := 1; i <= shifted && i <= unshifted; i++ {
+				variations += binomial(shifted+unshifted, i)
+			}
+			guesses *= variations
+		}
+	}
+
+	return guesses
+}
+
+// -- repeat
+
+func repeatMatches(rs []rune, inputs map[string]int) []strengthMatch {
+	var matches []strengthMatch
+
+	n := len(rs)
+	for i := 0; i < n; {
+		best, bestLen := 0, 0
+		for base := 1; base <= (n-i)/2; base++ {
+			count := 1
+			for i+(count+1)*base <= n && string(rs[i+count*base:i+(count+1)*base]) == string(rs[i:i+base]) {
+				count++
+			}
+
+			if count > 1 && count*base > bestLen {
+				best, bestLen = base, count*base
+			}
+		}
+
+		// Single character repeats need at least 3 characters.
+		if bestLen == 0 || (best == 1 && bestLen < 3) {
+			i++
+			continue
+		}
+
+		_, baseGuesses := estimateGuesses(rs[i:i+best], inputs)
+		matches = append(matches, strengthMatch{
+			pattern: patternRepeat,
+			i:       i,
+			j:       i + bestLen - 1,
+			guesses: baseGuesses * float64(bestLen/best),
+		})
+
+		i += bestLen
+	}
+
+	return matches
+}
+
+// -- sequence
+
+func sequenceMatches(rs []rune) []strengthMatch {
+	var matches []strengthMatch
+
+	n := len(rs)
+	for i := 0; i < n-2; {
+		delta := rs[i+1] - rs[i]
+		if delta != 1 && delta != -1 {
+			i++
+			continue
+		}
+
+		j := i + 1
+		for j+1 < n && rs[j+1]-rs[j] == delta {
+			j++
+		}
+
+		if j-i >= 2 {
+			matches = append(matches, strengthMatch{
+				pattern: patternSequence,
+				i:       i,
+				j:       j,
+				guesses: sequenceGuesses(rs[i], j-i+1, delta > 0),
+			})
+		}
+
+		i = j
+	}
+
+	return matches
+}
+
+func sequenceGuesses(first rune, length int, ascending bool) float64 {
+	var base float64
+	switch {
+	case strings.ContainsRune("aAzZ019", first):
+		base = 4
+	case unicode.IsDigit(first):
+		base = 10
+	default:
+		base = 26
+	}
+
+	if !ascending {
+		base *= 2
+	}
+
+	return base * float64(length)
+}
+
+// -- year
+
+func yearMatches(rs []rune) []strengthMatch {
+	var matches []strengthMatch
+
+	now := time.Now().Year()
+	for i := 0; i+4 <= len(rs); i++ {
+		year := 0
+		for _, r := range rs[i : i+4] {
+			if r < '0' || r > '9' {
+				year = -1
+				break
+			}
+
+			year = year*10 + int(r-'0')
+		}
+
+		if year < 1900 || year > 2099 {
+			continue
+		}
+
+		space := math.Abs(float64(year - now))
+		matches = append(matches, strengthMatch{
+			pattern: patternYear,
+			i:       i,
+			j:       i + 3,
+			guesses: math.Max(space, minYearSpace),
+		})
+	}
+
+	return matches
+}
+
+// -- feedback
+
+const suggestionAddWord = "Add another word or two. Uncommon words are better."
+
+func strengthFeedback(score int, seq []strengthMatch) (string, []string) {
+	if len(seq) == 0 {
+		return "", []string{
+			"Use a few words, avoid common phrases.",
+			"No need for symbols, digits, or uppercase letters.",
+		}
+	}
+
+	if score > 2 {
+		return "", nil
+	}
+
+	// Give feedback on the longest match.
+	longest := seq[0]
+	for _, m := range seq[1:] {
+		if m.j-m.i > longest.j-longest.i {
+			longest = m
+		}
+	}
+
+	suggestions := []string{suggestionAddWord}
+
+	switch longest.pattern {
+	case patternDictionary:
+		var warning string
+		switch {
+		case longest.userInput:
+			warning = "Passwords containing your name or email are easy to guess."
+		case len(seq) == 1 && !longest.l33t && !longest.reversed && longest.rank <= 10:
+			warning = "This is a top-10 common password."
+		case len(seq) == 1 && !longest.l33t && !longest.reversed && longest.rank <= 100:
+			warning = "This is a top-100 common password."
+		case len(seq) == 1:
+			warning = "This is similar to a commonly used password."
+		default:
+			warning = "Common passwords are easy to guess, even as part of a longer password."
+		}
+
+		if longest.capitalized {
+			suggestions = append(suggestions, "Capitalization doesn't help very much.")
+		} else if longest.allUpper {
+			suggestions = append(suggestions, "All-uppercase is almost as easy to guess as all-lowercase.")
+		}
+
+		if longest.reversed {
+			suggestions = append(suggestions, "Reversed words aren't much harder to guess.")
+		}
+
+		if longest.l33t {
+			suggestions = append(suggestions, "Predictable substitutions like '@' instead of 'a' don't help very much.")
+		}
+
+		return warning, suggestions
+	case patternSpatial:
+		warning := "Short keyboard patterns are easy to guess."
+		if longest.turns == 1 {
+			warning = "Straight rows of keys are easy to guess."
+		}
+
+		return warning, append(suggestions, "Use a longer keyboard pattern with more turns.")
+	case patternRepeat:
+		return "Repeats like \"abcabcabc\" are easy to guess.", append(suggestions, "Avoid repeated words and characters.")
+	case patternSequence:
+		return "Sequences like abc or 6543 are easy to guess.", append(suggestions, "Avoid sequences.")
+	case patternYear:
+		return "Recent years are easy to guess.", append(suggestions, "Avoid recent years, and years that are associated with you.")
+	default:
+		return "", suggestions
+	}
+}
@@ -0,0 +1,79 @@
+package domain_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordStrength(t *testing.T) {
+	testCases := []struct {
+		password string
+		score    int
+		warning  string
+	}{
+		{"", 0, ""},
+		{"password", 0, "This is a top-10 common password."},
+		{"Password1", 0, "This is similar to a commonly used password."},
+		{"p@ssw0rd", 0, "This is similar to a commonly used password."},
+		{"drowssap", 0, "This is similar to a commonly used password."},
+		{"qwertyuiop", 0, "This is a top-100 common password."},
+		{"zxcvbnm,./", 1, "Straight rows of keys are easy to guess."},
+		{"aaaaaaaa", 0, "Repeats like \"abcabcabc\" are easy to guess."},
+		{"abcdefgh", 0, "Sequences like abc or 6543 are easy to guess."},
+		{"1999", 0, "Recent years are easy to guess."},
+		{"kjh2#L9zq", 3, ""},
+		{"correcthorsebatterystaple", 4, ""},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.password, func(t *testing.T) {
+			assert := assert.New(t)
+
+			strength := domain.Plaintext(tc.password).Strength()
+			assert.Equal(tc.score, strength.Score)
+			assert.Equal(tc.warning, strength.Warning)
+			if tc.score > 2 {
+				assert.Empty(strength.Suggestions)
+			} else {
+				assert.NotEmpty(strength.Suggestions)
+			}
+		})
+	}
+}
+
+func TestPasswordStrengthUserInputs(t *testing.T) {
+	assert := assert.New(t)
+
+	without := domain.Plaintext("appleseed1990").Strength()
+	with := domain.Plaintext("appleseed1990").Strength("john", "appleseed")
+	assert.Less(with.Guesses, without.Guesses)
+	assert.Less(with.Score, without.Score)
+	assert.Equal("Passwords containing your name or email are easy to guess.", with.Warning)
+}
+
+func TestPasswordStrengthCrackTime(t *testing.T) {
+	assert := assert.New(t)
+
+	strength := domain.Plaintext("password").Strength()
+	assert.Less(strength.CrackTime, time.Second)
+
+	strength = domain.Plaintext("correcthorsebatterystaple").Strength()
+	assert.Greater(strength.CrackTime, 100*365*24*time.Hour)
+}
+
+func TestPasswordStrengthMaxLen(t *testing.T) {
+	assert := assert.New(t)
+
+	long := domain.Plaintext(strings.Repeat("kjh2#L9zq", 1000))
+
+	start := time.Now()
+	strength := long.Strength()
+	assert.Less(time.Since(start), time.Second)
+	assert.Equal(4, strength.Score)
+}
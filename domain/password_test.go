@@ -72,6 +72,23 @@ func TestPasswordFormat(t *testing.T) {
 	}
 }
 
+func TestPasswordValidateLengthOnly(t *testing.T) {
+	assert := assert.New(t)
+
+	policy := domain.DefaultPasswordPolicy
+	t.Cleanup(func() {
+		domain.DefaultPasswordPolicy = policy
+	})
+
+	domain.DefaultPasswordPolicy.RequireUpper = true
+	domain.DefaultPasswordPolicy.Denylist = []string{"12345678"}
+	domain.DefaultPasswordPolicy.MinScore = 4
+
+	// Existing passwords are not locked out by the stricter rules.
+	assert.Nil(domain.Plaintext("12345678").Validate())
+	assert.ErrorIs(domain.Plaintext("1234567").Validate(), domain.ErrPasswordTooShort)
+}
+
 func TestEncryptCompare(t *testing.T) {
 	assert := assert.New(t)
 
@@ -111,3 +128,40 @@ func TestPasswordEqual(t *testing.T) {
 	assert.True(pwd1.Equal(pwd2))
 	assert.False(pwd3.Equal(pwd2))
 }
+
+func TestDummyCiphertext(t *testing.T) {
+	assert := assert.New(t)
+
+	ciphertext := domain.DummyCiphertext()
+	assert.NotEmpty(ciphertext)
+	assert.Equal(ciphertext, domain.DummyCiphertext())
+	assert.False(ciphertext.Compare("12345678"))
+}
+
+func TestNeedsRehash(t *testing.T) {
+	assert := assert.New(t)
+
+	pwd := domain.Plaintext("12345678")
+	ciphertext, err := pwd.Encrypt()
+	assert.Nil(err)
+	assert.False(ciphertext.NeedsRehash())
+	assert.True(domain.Ciphertext("").NeedsRehash())
+	assert.True(domain.Ciphertext("$argon2id$m=1,t=1,p=1$salt$hash").NeedsRehash())
+
+	t.Cleanup(func() {
+		domain.RegisterPasswordHasher("argon2id", domain.DefaultArgon2idHasher)
+	})
+
+	hasher := domain.DefaultArgon2idHasher
+	hasher.Time++
+	domain.RegisterPasswordHasher("argon2id", hasher)
+	assert.True(ciphertext.NeedsRehash())
+
+	// Ciphertexts with old parameters can still be compared.
+	assert.True(ciphertext.Compare(pwd))
+
+	rehashed, err := pwd.Encrypt()
+	assert.Nil(err)
+	assert.False(rehashed.NeedsRehash())
+	assert.True(rehashed.Compare(pwd))
+}
@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Pepper errors.
+var (
+	ErrPepperInvalid = errors.New("pepper: invalid")
+)
+
+const pepperPrefix = "$pepper$kid="
+
+// Pepper is a secret key held outside of the database, e.g. in a secret
+// manager. The password is HMAC-ed with the pepper before it is hashed, so that
+// a leaked database alone cannot be brute-forced.
+//
+// The ID is embedded in the ciphertext, e.g.
+// $pepper$kid=2023-01$argon2id$m=65536,t=2,p=4$salt$hash.
+type Pepper struct {
+	ID  string
+	Key []byte
+}
+
+// Validate validates the pepper ID can be embedded in the ciphertext.
+func (p Pepper) Validate() error {
+	if p.ID == "" || strings.ContainsAny(p.ID, "$,=") {
+		return fmt.Errorf("%w: id %q", ErrPepperInvalid, p.ID)
+	}
+
+	if len(p.Key) == 0 {
+		return fmt.Errorf("%w: key required", ErrPepperInvalid)
+	}
+
+	return nil
+}
+
+func (p Pepper) String() string {
+	return p.ID
+}
+
+func (p Pepper) apply(password Plaintext) Plaintext {
+	// Normalize before the HMAC, like the hasher does, so that the same
+	// password typed on different devices matches.
+	mac := hmac.New(sha256.New, p.Key)
+	mac.Write(norm.NFKC.Bytes([]byte(password)))
+
+	// Encoded, since some hashers do not accept NUL bytes.
+	return Plaintext(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+var peppers = struct {
+	sync.RWMutex
+	current string
+	byID    map[string]Pepper
+}{}
+
+// UsePeppers sets the pepper for new ciphertexts. The previous peppers are
+// still used to compare existing ciphertexts, which report NeedsRehash until
+// they are encrypted with the current pepper. Rotate by passing the new pepper
+// as current, and the old pepper as previous.
+func UsePeppers(current Pepper, previous ...Pepper) error {
+	byID := make(map[string]Pepper)
+	for _, p := range append(previous, current) {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+
+		byID[p.ID] = p
+	}
+
+	peppers.Lock()
+	defer peppers.Unlock()
+
+	peppers.current = current.ID
+	peppers.byID = byID
+
+	return nil
+}
+
+// ClearPeppers removes all peppers.
+func ClearPeppers() {
+	peppers.Lock()
+	defer peppers.Unlock()
+
+	peppers.current = ""
+	peppers.byID = nil
+}
+
+func currentPepper() (Pepper, bool) {
+	peppers.RLock()
+	defer peppers.RUnlock()
+
+	p, ok := peppers.byID[peppers.current]
+	return p, ok
+}
+
+func pepperByID(id string) (Pepper, bool) {
+	peppers.RLock()
+	defer peppers.RUnlock()
+
+	p, ok := peppers.byID[id]
+	return p, ok
+}
+
+// splitPepper returns the pepper ID and the ciphertext of the hasher. The ID
+// is empty when the ciphertext is not peppered.
+func splitPepper(c Ciphertext) (string, Ciphertext) {
+	s := string(c)
+	if !strings.HasPrefix(s, pepperPrefix) {
+		return "", c
+	}
+
+	s = strings.TrimPrefix(s, pepperPrefix)
+	i := strings.IndexByte(s, '$')
+	if i <= 0 {
+		return "", c
+	}
+
+	return s[:i], Ciphertext(s[i:])
+}
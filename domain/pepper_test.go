@@ -0,0 +1,77 @@
+package domain_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPepper(t *testing.T) {
+	assert := assert.New(t)
+	t.Cleanup(domain.ClearPeppers)
+
+	pwd := domain.Plaintext("12345678")
+	unpeppered, err := pwd.Encrypt()
+	assert.Nil(err)
+
+	v1 := domain.Pepper{ID: "v1", Key: []byte("secret-1")}
+	v2 := domain.Pepper{ID: "v2", Key: []byte("secret-2")}
+
+	assert.Nil(domain.UsePeppers(v1))
+	assert.True(unpeppered.NeedsRehash())
+	assert.True(unpeppered.Compare(pwd))
+
+	c1, err := pwd.Encrypt()
+	assert.Nil(err)
+	assert.True(strings.HasPrefix(string(c1), "$pepper$kid=v1$argon2id$"), c1)
+	assert.True(c1.Compare(pwd))
+	assert.False(c1.Compare("87654321"))
+	assert.False(c1.NeedsRehash())
+
+	// The ciphertext without the pepper cannot be compared.
+	inner := domain.Ciphertext(strings.TrimPrefix(string(c1), "$pepper$kid=v1"))
+	assert.False(inner.Compare(pwd))
+
+	// Rotate.
+	assert.Nil(domain.UsePeppers(v2, v1))
+	assert.True(c1.Compare(pwd))
+	assert.True(c1.NeedsRehash())
+
+	c2, err := pwd.Encrypt()
+	assert.Nil(err)
+	assert.True(strings.HasPrefix(string(c2), "$pepper$kid=v2$"), c2)
+	assert.True(c2.Compare(pwd))
+	assert.False(c2.NeedsRehash())
+
+	// Retire the old pepper.
+	assert.Nil(domain.UsePeppers(v2))
+	assert.False(c1.Compare(pwd))
+	assert.True(c2.Compare(pwd))
+}
+
+func TestPepperNormalize(t *testing.T) {
+	assert := assert.New(t)
+	t.Cleanup(domain.ClearPeppers)
+
+	nfc := domain.Plaintext("caf\u00e9-12345678")
+	nfd := domain.Plaintext("cafe\u0301-12345678")
+	assert.NotEqual(nfc, nfd)
+
+	assert.Nil(domain.UsePeppers(domain.Pepper{ID: "v1", Key: []byte("secret-1")}))
+	c, err := nfc.Encrypt()
+	assert.Nil(err)
+	assert.True(c.Compare(nfd))
+}
+
+func TestPepperValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(domain.Pepper{ID: "2023-01", Key: []byte("secret")}.Validate())
+	assert.ErrorIs(domain.Pepper{ID: "", Key: []byte("secret")}.Validate(), domain.ErrPepperInvalid)
+	assert.ErrorIs(domain.Pepper{ID: "v$1", Key: []byte("secret")}.Validate(), domain.ErrPepperInvalid)
+	assert.ErrorIs(domain.Pepper{ID: "v1"}.Validate(), domain.ErrPepperInvalid)
+	assert.ErrorIs(domain.UsePeppers(domain.Pepper{ID: "v1"}), domain.ErrPepperInvalid)
+	assert.Equal("v1", domain.Pepper{ID: "v1", Key: []byte("secret")}.String())
+}
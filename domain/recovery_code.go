@@ -0,0 +1,162 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// RecoveryCode rules.
+const (
+	RecoveryCodeCount = 10
+	RecoveryCodeLen   = 20 // Excluding the separators, about 99 bits of entropy.
+)
+
+const (
+	recoveryCodePrefix   = "$hmac-sha256$"
+	recoveryCodeSaltLen  = 16
+	recoveryCodeGroupLen = 5
+)
+
+// Unambiguous lowercase characters, without 0, o, 1, i and l.
+const recoveryCodeAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+
+// RecoveryCode errors.
+var (
+	ErrRecoveryCodeInvalid = errors.New("recovery code: invalid format")
+)
+
+// RecoveryCode is a single-use code to login when the second factor is not
+// available. It is formatted as xxxxx-xxxxx-xxxxx-xxxxx.
+type RecoveryCode string
+
+// NewRecoveryCodes generates n random recovery codes.
+func NewRecoveryCodes(n int) ([]RecoveryCode, error) {
+	max := big.NewInt(int64(len(recoveryCodeAlphabet)))
+
+	codes := make([]RecoveryCode, n)
+	for i := range codes {
+		b := make([]byte, RecoveryCodeLen)
+		for j := range b {
+			k, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return nil, err
+			}
+
+			b[j] = recoveryCodeAlphabet[k.Int64()]
+		}
+
+		groups := make([]string, 0, RecoveryCodeLen/recoveryCodeGroupLen)
+		for j := 0; j < len(b); j += recoveryCodeGroupLen {
+			groups = append(groups, string(b[j:j+recoveryCodeGroupLen]))
+		}
+
+		codes[i] = RecoveryCode(strings.Join(groups, "-"))
+	}
+
+	return codes, nil
+}
+
+// Validate validates the recovery code format. The separator, whitespace and
+// casing are ignored.
+func (c RecoveryCode) Validate() error {
+	s := c.normalize()
+	if len(s) != RecoveryCodeLen {
+		return ErrRecoveryCodeInvalid
+	}
+
+	for _, r := range s {
+		if !strings.ContainsRune(recoveryCodeAlphabet, r) {
+			return ErrRecoveryCodeInvalid
+		}
+	}
+
+	return nil
+}
+
+// Encrypt hashes the recovery code with a salted HMAC-SHA256, keyed with the
+// pepper set by UsePeppers, e.g.
+// $hmac-sha256$kid=2023-01$salt$mac. A slow password hasher is not needed,
+// since the code is random and long enough to resist offline guessing without
+// the pepper, and it would make every LoginRecoveryCode attempt compare against
+// all codes slowly.
+func (c RecoveryCode) Encrypt() (Ciphertext, error) {
+	salt := make([]byte, recoveryCodeSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	var prefix string
+	pepper, peppered := currentPepper()
+	if peppered {
+		prefix = "kid=" + pepper.ID + "$"
+	}
+
+	enc := base64.RawStdEncoding
+	mac := c.mac(pepper.Key, salt)
+	return Ciphertext(recoveryCodePrefix + prefix + enc.EncodeToString(salt) + "$" + enc.EncodeToString(mac)), nil
+}
+
+// Match checks if the ciphertext is derived from the recovery code.
+func (c RecoveryCode) Match(ciphertext Ciphertext) bool {
+	s := string(ciphertext)
+	if !strings.HasPrefix(s, recoveryCodePrefix) {
+		return false
+	}
+
+	var key []byte
+	parts := strings.Split(strings.TrimPrefix(s, recoveryCodePrefix), "$")
+	if len(parts) == 3 {
+		id, ok := strings.CutPrefix(parts[0], "kid=")
+		if !ok {
+			return false
+		}
+
+		pepper, ok := pepperByID(id)
+		if !ok {
+			return false
+		}
+
+		key = pepper.Key
+		parts = parts[1:]
+	}
+
+	if len(parts) != 2 {
+		return false
+	}
+
+	enc := base64.RawStdEncoding
+	salt, err := enc.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+
+	mac, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+
+	// Constant time comparison.
+	return hmac.Equal(mac, c.mac(key, salt))
+}
+
+func (c RecoveryCode) mac(key, salt []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(salt)
+	h.Write([]byte(c.normalize()))
+	return h.Sum(nil)
+}
+
+func (c RecoveryCode) normalize() string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+
+		return r
+	}, strings.ToLower(strings.TrimSpace(string(c))))
+}
@@ -0,0 +1,85 @@
+package domain_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoveryCode(t *testing.T) {
+	t.Run("generate", func(t *testing.T) {
+		assert := assert.New(t)
+
+		codes, err := domain.NewRecoveryCodes(domain.RecoveryCodeCount)
+		assert.Nil(err)
+		assert.Len(codes, domain.RecoveryCodeCount)
+
+		seen := make(map[domain.RecoveryCode]bool)
+		for _, code := range codes {
+			assert.Nil(code.Validate())
+			assert.Len(string(code), domain.RecoveryCodeLen+3)
+			assert.False(seen[code])
+			seen[code] = true
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.ErrorIs(domain.RecoveryCode("").Validate(), domain.ErrRecoveryCodeInvalid)
+		assert.ErrorIs(domain.RecoveryCode("abcde-fgh").Validate(), domain.ErrRecoveryCodeInvalid)
+		assert.ErrorIs(domain.RecoveryCode("abcde-fghjk").Validate(), domain.ErrRecoveryCodeInvalid)
+		assert.ErrorIs(domain.RecoveryCode("abcde-fghjk-mnpqr-stuv0").Validate(), domain.ErrRecoveryCodeInvalid)
+		assert.Nil(domain.RecoveryCode("abcde-fghjk-mnpqr-stuvw").Validate())
+	})
+
+	t.Run("encrypt match", func(t *testing.T) {
+		assert := assert.New(t)
+
+		codes, err := domain.NewRecoveryCodes(2)
+		assert.Nil(err)
+
+		ciphertext, err := codes[0].Encrypt()
+		assert.Nil(err)
+		assert.True(codes[0].Match(ciphertext))
+		assert.False(codes[1].Match(ciphertext))
+
+		// Separator and casing are ignored.
+		typed := strings.ToUpper(strings.ReplaceAll(string(codes[0]), "-", " "))
+		assert.True(domain.RecoveryCode(typed).Match(ciphertext))
+		assert.True(strings.HasPrefix(string(ciphertext), "$hmac-sha256$"), ciphertext)
+
+		// Other formats do not match.
+		password, err := domain.Plaintext(strings.ReplaceAll(string(codes[0]), "-", "")).Encrypt()
+		assert.Nil(err)
+		assert.False(codes[0].Match(password))
+
+		// Salted.
+		other, err := codes[0].Encrypt()
+		assert.Nil(err)
+		assert.NotEqual(ciphertext, other)
+	})
+
+	t.Run("peppered", func(t *testing.T) {
+		assert := assert.New(t)
+		t.Cleanup(domain.ClearPeppers)
+
+		codes, err := domain.NewRecoveryCodes(1)
+		assert.Nil(err)
+
+		unpeppered, err := codes[0].Encrypt()
+		assert.Nil(err)
+
+		assert.Nil(domain.UsePeppers(domain.Pepper{ID: "v1", Key: []byte("secret-1")}))
+		ciphertext, err := codes[0].Encrypt()
+		assert.Nil(err)
+		assert.True(strings.HasPrefix(string(ciphertext), "$hmac-sha256$kid=v1$"), ciphertext)
+		assert.True(codes[0].Match(ciphertext))
+		assert.True(codes[0].Match(unpeppered))
+
+		domain.ClearPeppers()
+		assert.False(codes[0].Match(ciphertext))
+	})
+}
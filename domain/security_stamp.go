@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+)
+
+const securityStampLen = 16
+
+var ErrSecurityStampInvalid = errors.New("security stamp: invalid")
+
+// SecurityStamp changes whenever the credentials of the User change. Tokens
+// that embed the stamp are revoked when it no longer matches.
+//
+// The stamp is either derived from the current ciphertext with
+// Ciphertext.Stamp, which changes on every password change since the
+// ciphertext is salted, or generated with NewSecurityStamp, stored per User,
+// and replaced when the credentials change, e.g. by the UpdateSecurityStamp
+// step of the auth flows.
+type SecurityStamp string
+
+// NewSecurityStamp returns a random stamp.
+func NewSecurityStamp() (SecurityStamp, error) {
+	b := make([]byte, securityStampLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return SecurityStamp(base64.RawURLEncoding.EncodeToString(b)), nil
+}
+
+func (s SecurityStamp) Validate() error {
+	if s == "" {
+		return ErrSecurityStampInvalid
+	}
+
+	return nil
+}
+
+// Equal checks if two stamps match in constant time. Empty stamps never
+// match.
+func (s SecurityStamp) Equal(other SecurityStamp) bool {
+	if s == "" || other == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(s), []byte(other)) == 1
+}
+
+// Stamp returns a fingerprint of the ciphertext, that does not reveal the
+// ciphertext.
+func (c Ciphertext) Stamp() SecurityStamp {
+	sum := sha256.Sum256([]byte(c))
+	return SecurityStamp(base64.RawURLEncoding.EncodeToString(sum[:securityStampLen]))
+}
@@ -0,0 +1,41 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityStamp(t *testing.T) {
+	assert := assert.New(t)
+
+	s1, err := domain.NewSecurityStamp()
+	assert.Nil(err)
+	assert.Nil(s1.Validate())
+
+	s2, err := domain.NewSecurityStamp()
+	assert.Nil(err)
+
+	assert.True(s1.Equal(s1))
+	assert.False(s1.Equal(s2))
+	assert.False(domain.SecurityStamp("").Equal(""))
+	assert.ErrorIs(domain.SecurityStamp("").Validate(), domain.ErrSecurityStampInvalid)
+}
+
+func TestCiphertextStamp(t *testing.T) {
+	assert := assert.New(t)
+
+	c1, err := domain.Plaintext("12345678").Encrypt()
+	assert.Nil(err)
+
+	c2, err := domain.Plaintext("12345678").Encrypt()
+	assert.Nil(err)
+
+	assert.True(c1.Stamp().Equal(c1.Stamp()))
+	assert.NotContains(string(c1.Stamp()), string(c1))
+
+	// The ciphertext is salted, so the stamp changes even for the same
+	// password.
+	assert.False(c1.Stamp().Equal(c2.Stamp()))
+}
@@ -0,0 +1,73 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alextanhongpin/go-service-oriented-package/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// Base32 encoding of the RFC 6238 SHA1 test key "12345678901234567890".
+const rfcSecret = domain.TOTPSecret("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+
+func TestTOTPCode(t *testing.T) {
+	// The RFC test vectors are 8 digits, the last 6 digits are expected.
+	testCases := []struct {
+		unix int64
+		code domain.OTP
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tc := range testCases {
+		code, err := rfcSecret.Code(time.Unix(tc.unix, 0))
+		assert.Nil(t, err)
+		assert.Equal(t, tc.code, code, tc.unix)
+	}
+}
+
+func TestTOTPVerify(t *testing.T) {
+	assert := assert.New(t)
+
+	secret, err := domain.NewTOTPSecret()
+	assert.Nil(err)
+	assert.Nil(secret.Validate())
+
+	now := time.Now()
+	code, err := secret.Code(now)
+	assert.Nil(err)
+
+	step, ok := secret.Verify(code, now)
+	assert.True(ok)
+	assert.Equal(domain.TOTPTimeStep(now), step)
+
+	// Accepts the previous time step for clock skew.
+	step, ok = secret.Verify(code, now.Add(domain.TOTPPeriod))
+	assert.True(ok)
+	assert.Equal(domain.TOTPTimeStep(now), step)
+
+	_, ok = secret.Verify(code, now.Add(3*domain.TOTPPeriod))
+	assert.False(ok)
+
+	_, ok = secret.Verify("000000x", now)
+	assert.False(ok)
+}
+
+func TestTOTPSecret(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.ErrorIs(domain.TOTPSecret("").Validate(), domain.ErrTOTPSecretInvalid)
+	assert.ErrorIs(domain.TOTPSecret("not base32!").Validate(), domain.ErrTOTPSecretInvalid)
+	assert.Equal("*TOTP SECRET REDACTED*", rfcSecret.String())
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := rfcSecret.URI("Acme Co", domain.Email("john.doe@mail.com"))
+	want := "otpauth://totp/Acme%20Co:john.doe@mail.com?algorithm=SHA1&digits=6&issuer=Acme+Co&period=30&secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	assert.Equal(t, want, uri)
+}
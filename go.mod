@@ -6,6 +6,8 @@ require (
 	github.com/alextanhongpin/passwd v0.2.0
 	github.com/nyaruka/phonenumbers v1.1.7
 	github.com/stretchr/testify v1.8.4
+	golang.org/x/crypto v0.9.0
+	golang.org/x/text v0.9.0
 	golang.org/x/time v0.3.0
 )
 
@@ -15,9 +17,7 @@ require (
 	github.com/google/go-cmp v0.5.9 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/objx v0.5.0 // indirect
-	golang.org/x/crypto v0.9.0 // indirect
 	golang.org/x/sys v0.8.0 // indirect
-	golang.org/x/text v0.9.0 // indirect
 	google.golang.org/protobuf v1.30.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )